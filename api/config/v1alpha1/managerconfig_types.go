@@ -0,0 +1,176 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+
+// ManagerConfig is the configuration file of the namespacelabel manager.
+// Every field is optional; a field that is set takes precedence over the
+// command-line flag of the same purpose.
+type ManagerConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Policy decides which label keys NamespaceLabels may set. Changes are
+	// applied without a restart.
+	Policy Policy `json:"policy,omitempty"`
+
+	// Namespaces selects the namespaces that are managed. Changes require a
+	// restart.
+	Namespaces Namespaces `json:"namespaces,omitempty"`
+
+	// Limits bounds the load the controller puts on the API server. Changes
+	// to the Namespace write cap are applied without a restart; the others
+	// require one.
+	Limits Limits `json:"limits,omitempty"`
+
+	// Audit configures the audit log. Changes require a restart.
+	Audit Audit `json:"audit,omitempty"`
+
+	// Defaults are the labels the Namespace webhook adds to new namespaces.
+	// Changes to the labels are applied without a restart.
+	Defaults Defaults `json:"defaults,omitempty"`
+}
+
+// Policy decides which label keys NamespaceLabels may set.
+type Policy struct {
+	// ProtectedPrefixes are the label key prefixes tenants may not set. A key
+	// is protected when its prefix equals one of them or is a subdomain of
+	// one. Unset keeps the built-in list; an empty list protects nothing.
+	ProtectedPrefixes []string `json:"protectedPrefixes,omitempty"`
+
+	// MaxPodSecurityLevel is the most privileged Pod Security enforce level
+	// a NamespaceLabel may request: privileged, baseline or restricted.
+	MaxPodSecurityLevel string `json:"maxPodSecurityLevel,omitempty"`
+
+	// AllowedSecrets are glob patterns of the Secret names whose keys
+	// spec.labelsFrom may copy into namespace labels. Unset allows none.
+	AllowedSecrets []string `json:"allowedSecrets,omitempty"`
+
+	// AllowedNamespaceRefs are glob patterns of the Namespaces whose labels
+	// spec.labelsFrom may copy, besides the NamespaceLabel's own namespace.
+	// Unset allows none.
+	AllowedNamespaceRefs []string `json:"allowedNamespaceRefs,omitempty"`
+
+	// KeyMigrations move labels from deprecated keys to new ones.
+	KeyMigrations []KeyMigration `json:"keyMigrations,omitempty"`
+
+	// OverrideFieldManagers are glob patterns of the field managers whose
+	// changes to requested labels are set back. Labels other field managers
+	// own are left to them. Unset uses kubectl, kubectl-* and manager.
+	OverrideFieldManagers []string `json:"overrideFieldManagers,omitempty"`
+
+	// LabelQuota caps the number and size of namespace labels. A
+	// NamespaceLabel breaking it gets none of its labels applied.
+	LabelQuota LabelQuota `json:"labelQuota,omitempty"`
+}
+
+// LabelQuota caps the labels NamespaceLabels may put on a namespace. Unset
+// or zero fields are no limit.
+type LabelQuota struct {
+	// MaxLabelsPerNamespace caps the labels of a namespace, whoever set them.
+	MaxLabelsPerNamespace *int32 `json:"maxLabelsPerNamespace,omitempty"`
+
+	// MaxLabelsPerObject caps the keys a single NamespaceLabel requests.
+	MaxLabelsPerObject *int32 `json:"maxLabelsPerObject,omitempty"`
+
+	// MaxLabelBytesPerNamespace caps the summed length in bytes of the keys
+	// and values of the labels of a namespace.
+	MaxLabelBytesPerNamespace *int32 `json:"maxLabelBytesPerNamespace,omitempty"`
+}
+
+// KeyMigration moves a label from a deprecated key to a new one. A
+// NamespaceLabel requesting either key gets the label under both while
+// DualWrite is on, and is reported until its spec uses the new key.
+type KeyMigration struct {
+	// From is the deprecated key.
+	From string `json:"from"`
+	// To is the key replacing it.
+	To string `json:"to"`
+	// DualWrite keeps writing From next to To. Defaults to true; turn it
+	// off once nothing reads From any more.
+	DualWrite *bool `json:"dualWrite,omitempty"`
+}
+
+// Namespaces selects the namespaces that are managed.
+type Namespaces struct {
+	// Selector is a label selector namespaces must match, e.g. "tenant=true".
+	Selector string `json:"selector,omitempty"`
+
+	// Exclude are glob patterns of namespace names that are never managed.
+	// Unset keeps the built-in list.
+	Exclude []string `json:"exclude,omitempty"`
+
+	// Watch, if set, restricts the manager to these namespaces so it can run
+	// with namespaced RBAC.
+	Watch []string `json:"watch,omitempty"`
+}
+
+// Limits bounds the load the controller puts on the API server.
+type Limits struct {
+	// MaxConcurrentReconciles is the number of NamespaceLabels reconciled at
+	// the same time.
+	MaxConcurrentReconciles *int32 `json:"maxConcurrentReconciles,omitempty"`
+
+	// RequeueBaseDelay is the first backoff delay of a NamespaceLabel whose
+	// reconcile failed; it doubles on every failure.
+	RequeueBaseDelay *metav1.Duration `json:"requeueBaseDelay,omitempty"`
+
+	// RequeueMaxDelay is the longest backoff delay of a failing NamespaceLabel.
+	RequeueMaxDelay *metav1.Duration `json:"requeueMaxDelay,omitempty"`
+
+	// RequeueQPS is the overall rate at which NamespaceLabels are requeued.
+	RequeueQPS *float64 `json:"requeueQPS,omitempty"`
+
+	// RequeueBurst is the number of requeues allowed at once above RequeueQPS.
+	RequeueBurst *int32 `json:"requeueBurst,omitempty"`
+
+	// NamespaceWritesPerSecond caps the patches sent to Namespaces. Zero
+	// disables the cap.
+	NamespaceWritesPerSecond *float64 `json:"namespaceWritesPerSecond,omitempty"`
+
+	// NamespaceWriteBurst is the number of Namespace patches allowed at once
+	// above NamespaceWritesPerSecond.
+	NamespaceWriteBurst *int32 `json:"namespaceWriteBurst,omitempty"`
+}
+
+// Audit configures the audit log.
+type Audit struct {
+	// LogPath is the file every namespace label change is appended to as a
+	// JSON line, or "-" for stdout.
+	LogPath string `json:"logPath,omitempty"`
+}
+
+// Defaults are the labels the Namespace webhook adds to new namespaces.
+type Defaults struct {
+	// Labels are added to every new managed namespace that does not set the
+	// key itself. "${user}" in a value is replaced with the name of the user
+	// creating the namespace.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// NamespaceLabel is the name of the NamespaceLabel created in the new
+	// namespace to own the added labels. Defaults to "defaults". Changes
+	// require a restart.
+	NamespaceLabel string `json:"namespaceLabel,omitempty"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ManagerConfig{})
+}
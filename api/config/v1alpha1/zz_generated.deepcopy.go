@@ -0,0 +1,260 @@
+//go:build !ignore_autogenerated
+
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Audit) DeepCopyInto(out *Audit) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Audit.
+func (in *Audit) DeepCopy() *Audit {
+	if in == nil {
+		return nil
+	}
+	out := new(Audit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Defaults) DeepCopyInto(out *Defaults) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Defaults.
+func (in *Defaults) DeepCopy() *Defaults {
+	if in == nil {
+		return nil
+	}
+	out := new(Defaults)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyMigration) DeepCopyInto(out *KeyMigration) {
+	*out = *in
+	if in.DualWrite != nil {
+		in, out := &in.DualWrite, &out.DualWrite
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyMigration.
+func (in *KeyMigration) DeepCopy() *KeyMigration {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyMigration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabelQuota) DeepCopyInto(out *LabelQuota) {
+	*out = *in
+	if in.MaxLabelsPerNamespace != nil {
+		in, out := &in.MaxLabelsPerNamespace, &out.MaxLabelsPerNamespace
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxLabelsPerObject != nil {
+		in, out := &in.MaxLabelsPerObject, &out.MaxLabelsPerObject
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxLabelBytesPerNamespace != nil {
+		in, out := &in.MaxLabelBytesPerNamespace, &out.MaxLabelBytesPerNamespace
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabelQuota.
+func (in *LabelQuota) DeepCopy() *LabelQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(LabelQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Limits) DeepCopyInto(out *Limits) {
+	*out = *in
+	if in.MaxConcurrentReconciles != nil {
+		in, out := &in.MaxConcurrentReconciles, &out.MaxConcurrentReconciles
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RequeueBaseDelay != nil {
+		in, out := &in.RequeueBaseDelay, &out.RequeueBaseDelay
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.RequeueMaxDelay != nil {
+		in, out := &in.RequeueMaxDelay, &out.RequeueMaxDelay
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.RequeueQPS != nil {
+		in, out := &in.RequeueQPS, &out.RequeueQPS
+		*out = new(float64)
+		**out = **in
+	}
+	if in.RequeueBurst != nil {
+		in, out := &in.RequeueBurst, &out.RequeueBurst
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NamespaceWritesPerSecond != nil {
+		in, out := &in.NamespaceWritesPerSecond, &out.NamespaceWritesPerSecond
+		*out = new(float64)
+		**out = **in
+	}
+	if in.NamespaceWriteBurst != nil {
+		in, out := &in.NamespaceWriteBurst, &out.NamespaceWriteBurst
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Limits.
+func (in *Limits) DeepCopy() *Limits {
+	if in == nil {
+		return nil
+	}
+	out := new(Limits)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagerConfig) DeepCopyInto(out *ManagerConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.Policy.DeepCopyInto(&out.Policy)
+	in.Namespaces.DeepCopyInto(&out.Namespaces)
+	in.Limits.DeepCopyInto(&out.Limits)
+	out.Audit = in.Audit
+	in.Defaults.DeepCopyInto(&out.Defaults)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagerConfig.
+func (in *ManagerConfig) DeepCopy() *ManagerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ManagerConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Namespaces) DeepCopyInto(out *Namespaces) {
+	*out = *in
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Watch != nil {
+		in, out := &in.Watch, &out.Watch
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Namespaces.
+func (in *Namespaces) DeepCopy() *Namespaces {
+	if in == nil {
+		return nil
+	}
+	out := new(Namespaces)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Policy) DeepCopyInto(out *Policy) {
+	*out = *in
+	if in.ProtectedPrefixes != nil {
+		in, out := &in.ProtectedPrefixes, &out.ProtectedPrefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedSecrets != nil {
+		in, out := &in.AllowedSecrets, &out.AllowedSecrets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedNamespaceRefs != nil {
+		in, out := &in.AllowedNamespaceRefs, &out.AllowedNamespaceRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.KeyMigrations != nil {
+		in, out := &in.KeyMigrations, &out.KeyMigrations
+		*out = make([]KeyMigration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.OverrideFieldManagers != nil {
+		in, out := &in.OverrideFieldManagers, &out.OverrideFieldManagers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.LabelQuota.DeepCopyInto(&out.LabelQuota)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Policy.
+func (in *Policy) DeepCopy() *Policy {
+	if in == nil {
+		return nil
+	}
+	out := new(Policy)
+	in.DeepCopyInto(out)
+	return out
+}
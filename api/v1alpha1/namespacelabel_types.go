@@ -17,29 +17,295 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// Mode selects whether a NamespaceLabel changes its namespace.
+// +kubebuilder:validation:Enum=Apply;Plan
+type Mode string
+
+const (
+	// ModeApply applies the requested labels to the namespace.
+	ModeApply Mode = "Apply"
+	// ModePlan only reports the changes in status.plan.
+	ModePlan Mode = "Plan"
+)
+
 // NamespaceLabelSpec defines the desired state of NamespaceLabel.
 type NamespaceLabelSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Labels are the labels to set on the namespace the NamespaceLabel lives in.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Mode is Apply to change the namespace, or Plan to only preview the
+	// changes in status.plan.
+	// +kubebuilder:default=Apply
+	// +optional
+	Mode Mode `json:"mode,omitempty"`
+
+	// LabelsFrom are labels whose values are read from other objects. The
+	// namespace label follows its source when the source changes. A key set
+	// here takes precedence over the same key in Labels.
+	// +listType=map
+	// +listMapKey=key
+	// +optional
+	LabelsFrom []LabelFrom `json:"labelsFrom,omitempty"`
+
+	// PodSecurity sets the Pod Security Admission labels of the namespace.
+	// The enforce level may not be more privileged than the operator allows.
+	// +optional
+	PodSecurity *PodSecurity `json:"podSecurity,omitempty"`
+
+	// Propagate copies some of the applied labels to objects in the
+	// namespace.
+	// +optional
+	Propagate *Propagate `json:"propagate,omitempty"`
+}
+
+// PropagateKind is a kind of object labels may be propagated to.
+// +kubebuilder:validation:Enum=Deployment;StatefulSet;PersistentVolumeClaim
+type PropagateKind string
+
+const (
+	// PropagateDeployment selects apps/v1 Deployments.
+	PropagateDeployment PropagateKind = "Deployment"
+	// PropagateStatefulSet selects apps/v1 StatefulSets.
+	PropagateStatefulSet PropagateKind = "StatefulSet"
+	// PropagatePersistentVolumeClaim selects v1 PersistentVolumeClaims.
+	PropagatePersistentVolumeClaim PropagateKind = "PersistentVolumeClaim"
+)
+
+// Propagate selects labels to copy from the namespace to objects inside it.
+type Propagate struct {
+	// Keys are the label keys to copy. Only keys this object applies to the
+	// namespace are propagated.
+	// +listType=set
+	// +kubebuilder:validation:MinItems=1
+	Keys []string `json:"keys"`
+
+	// Kinds are the kinds of object that receive the labels.
+	// +listType=set
+	// +kubebuilder:validation:MinItems=1
+	Kinds []PropagateKind `json:"kinds"`
+
+	// PodTemplates also labels the pod template of Deployments and
+	// StatefulSets, so their Pods carry the labels. Changing a pod template
+	// rolls out new Pods.
+	// +optional
+	PodTemplates bool `json:"podTemplates,omitempty"`
+}
+
+// LabelFrom is a label whose value is read from another object.
+type LabelFrom struct {
+	// Key is the label key.
+	Key string `json:"key"`
+	// ValueFrom selects the source of the value.
+	ValueFrom LabelValueSource `json:"valueFrom"`
+}
+
+// LabelValueSource selects the source of a label value. Exactly one field
+// must be set.
+// +kubebuilder:validation:MinProperties=1
+// +kubebuilder:validation:MaxProperties=1
+type LabelValueSource struct {
+	// ConfigMapKeyRef selects a key of a ConfigMap in the same namespace.
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+	// SecretKeyRef selects a key of a Secret in the same namespace. The
+	// operator's policy must allow the Secret.
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+	// NamespaceRef selects a label of another Namespace.
+	// +optional
+	NamespaceRef *NamespaceKeySelector `json:"namespaceRef,omitempty"`
+}
+
+// NamespaceKeySelector selects a label of a Namespace.
+type NamespaceKeySelector struct {
+	// Name is the name of the Namespace.
+	Name string `json:"name"`
+	// Key is the label key.
+	Key string `json:"key"`
+	// Optional leaves the label unset instead of rejecting it when the
+	// Namespace or the label does not exist.
+	// +optional
+	Optional *bool `json:"optional,omitempty"`
+}
+
+// PodSecurityLevel is a Pod Security Standards level.
+// +kubebuilder:validation:Enum=privileged;baseline;restricted
+type PodSecurityLevel string
+
+const (
+	// PodSecurityPrivileged allows everything.
+	PodSecurityPrivileged PodSecurityLevel = "privileged"
+	// PodSecurityBaseline prevents known privilege escalations.
+	PodSecurityBaseline PodSecurityLevel = "baseline"
+	// PodSecurityRestricted enforces current pod hardening practices.
+	PodSecurityRestricted PodSecurityLevel = "restricted"
+)
+
+// PodSecurity selects the Pod Security Admission level of each mode.
+type PodSecurity struct {
+	// Enforce rejects pods violating the level.
+	// +optional
+	Enforce *PodSecurityMode `json:"enforce,omitempty"`
+	// Audit records violations in the audit log.
+	// +optional
+	Audit *PodSecurityMode `json:"audit,omitempty"`
+	// Warn returns a warning to the user creating a violating pod.
+	// +optional
+	Warn *PodSecurityMode `json:"warn,omitempty"`
+}
+
+// PodSecurityMode is the level and policy version of one Pod Security
+// Admission mode.
+type PodSecurityMode struct {
+	// Level is the Pod Security Standards level.
+	Level PodSecurityLevel `json:"level"`
+	// Version pins the policy version, e.g. v1.30. Unset uses latest.
+	// +kubebuilder:validation:Pattern=`^(latest|v[0-9]+\.[0-9]+)$`
+	// +optional
+	Version string `json:"version,omitempty"`
+}
+
+// Reasons a requested label may not be applied to the namespace.
+const (
+	// RejectedReasonProtected means the key belongs to a protected prefix.
+	RejectedReasonProtected = "Protected"
+	// RejectedReasonInvalid means the key or value is not a valid label.
+	RejectedReasonInvalid = "Invalid"
+	// RejectedReasonConflict means another NamespaceLabel already owns the key.
+	RejectedReasonConflict = "Conflict"
+	// RejectedReasonUnresolved means the source of a labelsFrom value does
+	// not exist.
+	RejectedReasonUnresolved = "Unresolved"
+	// RejectedReasonForbiddenSource means the policy does not allow reading
+	// the source of a labelsFrom value.
+	RejectedReasonForbiddenSource = "ForbiddenSource"
+	// RejectedReasonTooPrivileged means the Pod Security level is more
+	// privileged than the operator allows.
+	RejectedReasonTooPrivileged = "TooPrivileged"
+	// RejectedReasonForeignOwner means another field manager, such as a
+	// GitOps tool, owns the key on the namespace with a different value.
+	RejectedReasonForeignOwner = "ForeignOwner"
+)
+
+// RejectedLabel describes a requested label that was not applied.
+type RejectedLabel struct {
+	// Key is the label key that was rejected.
+	Key string `json:"key"`
+	// Reason is a machine readable reason for the rejection.
+	Reason string `json:"reason"`
+	// Message is a human readable explanation of the rejection.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
 
-	// Foo is an example field of NamespaceLabel. Edit namespacelabel_types.go to remove/update
-	Foo string `json:"foo,omitempty"`
+// LabelChange is a single change the controller plans to make to a
+// namespace label.
+type LabelChange struct {
+	// Key is the label key.
+	Key string `json:"key"`
+	// OldValue is the value on the namespace before the change, empty for adds.
+	// +optional
+	OldValue string `json:"oldValue,omitempty"`
+	// NewValue is the value after the change, empty for removals.
+	// +optional
+	NewValue string `json:"newValue,omitempty"`
+}
+
+// LabelPlan lists the changes the controller would make to the namespace.
+type LabelPlan struct {
+	// Add are the labels that would be added.
+	// +optional
+	Add []LabelChange `json:"add,omitempty"`
+	// Update are the labels whose value would change.
+	// +optional
+	Update []LabelChange `json:"update,omitempty"`
+	// Remove are the labels that would be removed.
+	// +optional
+	Remove []LabelChange `json:"remove,omitempty"`
+	// Rejected are the requested labels that would not be applied.
+	// +optional
+	Rejected []RejectedLabel `json:"rejected,omitempty"`
+}
+
+// ConditionReady is the condition type reporting whether every requested
+// label has been applied to the namespace.
+const ConditionReady = "Ready"
+
+// ConditionForeignOwner is the condition type reporting whether field
+// managers other than the operator own requested keys on the namespace.
+const ConditionForeignOwner = "ForeignOwner"
+
+// ForeignOwner lists the field managers other than the operator that own a
+// label key on the namespace.
+type ForeignOwner struct {
+	// Key is the label key.
+	Key string `json:"key"`
+	// Managers are the field managers owning the key.
+	Managers []string `json:"managers"`
 }
 
 // NamespaceLabelStatus defines the observed state of NamespaceLabel.
 type NamespaceLabelStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// AppliedLabels are the labels this object currently owns on its namespace.
+	// +optional
+	AppliedLabels map[string]string `json:"appliedLabels,omitempty"`
+
+	// PropagatedLabels are the labels this object last copied to objects in
+	// its namespace.
+	// +optional
+	PropagatedLabels map[string]string `json:"propagatedLabels,omitempty"`
+
+	// ForeignOwners are the requested keys that field managers other than
+	// the operator also own on the namespace.
+	// +listType=map
+	// +listMapKey=key
+	// +optional
+	ForeignOwners []ForeignOwner `json:"foreignOwners,omitempty"`
+
+	// Profiles are the NamespaceProfiles whose objects this object created in
+	// its namespace.
+	// +optional
+	Profiles []string `json:"profiles,omitempty"`
+
+	// DeprecatedKeys are the requested keys the operator is renaming. The
+	// labels are written under the new key too; the spec should move to it.
+	// +optional
+	DeprecatedKeys []string `json:"deprecatedKeys,omitempty"`
+
+	// RejectedLabels are the requested labels that were not applied.
+	// +optional
+	RejectedLabels []RejectedLabel `json:"rejectedLabels,omitempty"`
+
+	// Plan lists the changes that were computed but not made, because the
+	// object is in Plan mode or the controller runs in dry-run mode. It is
+	// empty once changes are applied.
+	// +optional
+	Plan *LabelPlan `json:"plan,omitempty"`
+
+	// ObservedGeneration is the generation of the spec last reconciled.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions represent the latest observations of the object's state.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.spec.mode`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // NamespaceLabel is the Schema for the namespacelabels API.
 type NamespaceLabel struct {
@@ -0,0 +1,107 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NamespaceProfileSpec defines the desired state of NamespaceProfile.
+type NamespaceProfileSpec struct {
+	// Key is the label key selecting namespaces.
+	Key string `json:"key"`
+
+	// Value is the label value selecting namespaces. The profile applies to
+	// a namespace when a NamespaceLabel in it applies Key=Value.
+	Value string `json:"value"`
+
+	// ResourceQuotas are created in every selected namespace.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	ResourceQuotas []ResourceQuotaTemplate `json:"resourceQuotas,omitempty"`
+
+	// LimitRanges are created in every selected namespace.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	LimitRanges []LimitRangeTemplate `json:"limitRanges,omitempty"`
+
+	// NetworkPolicies are created in every selected namespace.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	NetworkPolicies []NetworkPolicyTemplate `json:"networkPolicies,omitempty"`
+}
+
+// ResourceQuotaTemplate is a ResourceQuota created by a profile.
+type ResourceQuotaTemplate struct {
+	// Name is the name of the ResourceQuota in the namespace.
+	// +kubebuilder:validation:MaxLength=253
+	Name string `json:"name"`
+	// Spec is the spec of the ResourceQuota.
+	Spec corev1.ResourceQuotaSpec `json:"spec"`
+}
+
+// LimitRangeTemplate is a LimitRange created by a profile.
+type LimitRangeTemplate struct {
+	// Name is the name of the LimitRange in the namespace.
+	// +kubebuilder:validation:MaxLength=253
+	Name string `json:"name"`
+	// Spec is the spec of the LimitRange.
+	Spec corev1.LimitRangeSpec `json:"spec"`
+}
+
+// NetworkPolicyTemplate is a NetworkPolicy created by a profile.
+type NetworkPolicyTemplate struct {
+	// Name is the name of the NetworkPolicy in the namespace.
+	// +kubebuilder:validation:MaxLength=253
+	Name string `json:"name"`
+	// Spec is the spec of the NetworkPolicy.
+	Spec networkingv1.NetworkPolicySpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Key",type=string,JSONPath=`.spec.key`
+// +kubebuilder:printcolumn:name="Value",type=string,JSONPath=`.spec.value`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NamespaceProfile is the Schema for the namespaceprofiles API. It maps a
+// namespace label to a bundle of objects created in every namespace whose
+// NamespaceLabel applies that label.
+type NamespaceProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NamespaceProfileSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NamespaceProfileList contains a list of NamespaceProfile.
+type NamespaceProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespaceProfile `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespaceProfile{}, &NamespaceProfileList{})
+}
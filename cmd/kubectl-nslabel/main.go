@@ -0,0 +1,33 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+
+	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
+	// so the plugin works with the same kubeconfigs as kubectl.
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+
+	"github.com/TalDebi/namespacelabel/internal/cli"
+)
+
+func main() {
+	if err := cli.NewRootCommand(os.Stdout).Execute(); err != nil {
+		os.Exit(1)
+	}
+}
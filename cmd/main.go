@@ -17,26 +17,51 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
+	"fmt"
+	"net"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	configv1alpha1 "github.com/TalDebi/namespacelabel/api/config/v1alpha1"
 	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/audit"
+	"github.com/TalDebi/namespacelabel/internal/certs"
+	"github.com/TalDebi/namespacelabel/internal/config"
 	"github.com/TalDebi/namespacelabel/internal/controller"
+	"github.com/TalDebi/namespacelabel/internal/health"
+	"github.com/TalDebi/namespacelabel/internal/metrics"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+	"github.com/TalDebi/namespacelabel/internal/tracing"
+	webhookv1 "github.com/TalDebi/namespacelabel/internal/webhook/v1"
+	webhookv1alpha1 "github.com/TalDebi/namespacelabel/internal/webhook/v1alpha1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -47,6 +72,7 @@ var (
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
 
 	utilruntime.Must(danaiov1alpha1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
@@ -58,6 +84,24 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var dryRun bool
+	var tracingOpts tracing.Options
+	var watchNamespaces string
+	var namespaceExclude string
+	var configPath string
+	var enableWebhooks bool
+	var webhookCertDir string
+	var webhookCertRotation bool
+	var webhookService string
+	var webhookCertSecret string
+	var labelAdminGroups string
+	var stallTimeout time.Duration
+	settings := config.Settings{
+		Policy:       plan.DefaultPolicy(),
+		Limits:       config.DefaultLimits(),
+		DefaultsName: "defaults",
+	}
+	limits := &settings.Limits
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -69,6 +113,61 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&settings.AuditLogPath, "audit-log", "",
+		"If set, every namespace label change is appended as a JSON line to this file. "+
+			"Use - to write to stdout, or leave empty to disable the audit log.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"If set, label changes are computed, validated with a server-side dry-run and reported "+
+			"in each NamespaceLabel's status.plan and events, but never written to namespaces. "+
+			"Default labels for new namespaces are only logged.")
+	flag.StringVar(&tracingOpts.Endpoint, "otlp-endpoint", "",
+		"The host:port of an OTLP/gRPC collector to export traces to. Leave empty to disable tracing.")
+	flag.BoolVar(&tracingOpts.Insecure, "otlp-insecure", false,
+		"If set, traces are exported to the OTLP collector without TLS.")
+	flag.Float64Var(&tracingOpts.SampleRatio, "trace-sample-ratio", 1,
+		"The fraction of reconciles that start a new sampled trace.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"A comma-separated list of namespaces to manage. If set, only NamespaceLabels and Namespaces "+
+			"with these names are watched, so the manager can run with the namespaced RBAC in config/namespaced.")
+	flag.StringVar(&settings.NamespaceSelector, "namespace-selector", "",
+		"A label selector namespaces must match for their NamespaceLabels to be managed, e.g. tenant=true. "+
+			"NamespaceLabels in other namespaces are refused with a Ready=False condition.")
+	flag.StringVar(&namespaceExclude, "namespace-exclude", strings.Join(plan.DefaultExcludedNamespaces, ","),
+		"A comma-separated list of glob patterns of namespace names whose NamespaceLabels are never managed.")
+	flag.IntVar(&limits.MaxConcurrentReconciles, "max-concurrent-reconciles", limits.MaxConcurrentReconciles,
+		"The number of NamespaceLabels reconciled at the same time.")
+	flag.DurationVar(&limits.BaseDelay, "requeue-base-delay", limits.BaseDelay,
+		"The first backoff delay of a NamespaceLabel whose reconcile failed; it doubles on every failure.")
+	flag.DurationVar(&limits.MaxDelay, "requeue-max-delay", limits.MaxDelay,
+		"The longest backoff delay of a NamespaceLabel whose reconcile keeps failing.")
+	flag.Float64Var(&limits.QPS, "requeue-qps", limits.QPS,
+		"The overall rate at which NamespaceLabels are requeued.")
+	flag.IntVar(&limits.Burst, "requeue-burst", limits.Burst,
+		"The number of NamespaceLabels that may be requeued at once above --requeue-qps.")
+	flag.Float64Var(&limits.NamespaceWritesPerSecond, "namespace-writes-per-second", 0,
+		"The maximum rate of patches to Namespaces across all reconciles. 0 disables the cap.")
+	flag.IntVar(&limits.NamespaceWriteBurst, "namespace-write-burst", limits.NamespaceWriteBurst,
+		"The number of Namespace patches allowed at once above --namespace-writes-per-second.")
+	flag.StringVar(&configPath, "config", "",
+		"The path of a ManagerConfig file. Fields set in it take precedence over the flags; "+
+			"changes to the policy and the Namespace write cap are applied without a restart.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"If set, the admission webhooks are served and readyz waits for a valid serving certificate.")
+	flag.StringVar(&webhookCertDir, "webhook-cert-dir",
+		filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs"),
+		"The directory the webhook server reads tls.crt and tls.key from.")
+	flag.BoolVar(&webhookCertRotation, "webhook-cert-rotation", true,
+		"If set with --enable-webhooks, the manager issues and rotates its own serving certificate. "+
+			"Disable it to serve a certificate mounted into --webhook-cert-dir, e.g. by cert-manager.")
+	flag.StringVar(&webhookService, "webhook-service", "nsl-operator-tal-webhook-service",
+		"The Service in the manager's namespace that the webhook configurations call.")
+	flag.StringVar(&webhookCertSecret, "webhook-cert-secret", "nsl-operator-tal-webhook-server-cert",
+		"The Secret in the manager's namespace storing the webhook CA and serving certificate.")
+	flag.StringVar(&labelAdminGroups, "label-admin-groups", "",
+		"A comma-separated list of groups whose members may change Namespace labels owned by a NamespaceLabel. "+
+			"Everyone else but the manager is denied by the Namespace webhook.")
+	flag.DurationVar(&stallTimeout, "reconcile-stall-timeout", 15*time.Minute,
+		"healthz fails when no reconcile succeeds for this long while NamespaceLabels are queued. 0 disables it.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -93,6 +192,7 @@ func main() {
 	}
 
 	webhookServer := webhook.NewServer(webhook.Options{
+		CertDir: webhookCertDir,
 		TLSOpts: tlsOpts,
 	})
 
@@ -118,8 +218,56 @@ func main() {
 		// this setup is not recommended for production.
 	}
 
+	settings.WatchNamespaces = splitList(watchNamespaces)
+	settings.ExcludeNamespaces = splitList(namespaceExclude)
+	flagSettings := settings
+	var managerConfig *configv1alpha1.ManagerConfig
+	if configPath != "" {
+		var err error
+		if managerConfig, err = config.Load(configPath); err != nil {
+			setupLog.Error(err, "invalid configuration file")
+			os.Exit(1)
+		}
+		config.Apply(managerConfig, &settings)
+	}
+
+	namespaceFilter := plan.NamespaceFilter{Exclude: settings.ExcludeNamespaces}
+	for _, pattern := range namespaceFilter.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			setupLog.Error(err, "invalid --namespace-exclude pattern", "pattern", pattern)
+			os.Exit(1)
+		}
+	}
+	if settings.NamespaceSelector != "" {
+		selector, err := labels.Parse(settings.NamespaceSelector)
+		if err != nil {
+			setupLog.Error(err, "invalid --namespace-selector")
+			os.Exit(1)
+		}
+		namespaceFilter.Selector = selector
+	}
+
+	namespaces := settings.WatchNamespaces
+	cacheOpts := cache.Options{
+		ByObject: map[client.Object]cache.ByObject{&corev1.Namespace{}: controller.NamespaceCache(namespaceFilter)},
+	}
+	var clientOpts client.Options
+	if len(namespaces) > 0 {
+		setupLog.Info("restricting the manager to namespaces", "namespaces", namespaces)
+		cacheOpts.DefaultNamespaces = map[string]cache.Config{}
+		for _, ns := range namespaces {
+			cacheOpts.DefaultNamespaces[ns] = cache.Config{}
+		}
+		// Namespaces are cluster-scoped, so caching them would need cluster-wide
+		// list access. Read them directly instead; the controller watches each
+		// one through its own cache.
+		clientOpts.Cache = &client.CacheOptions{DisableFor: []client.Object{&corev1.Namespace{}}}
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
+		Cache:                  cacheOpts,
+		Client:                 clientOpts,
 		Metrics:                metricsServerOptions,
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
@@ -142,23 +290,163 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = (&controller.NamespaceLabelReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
+	if tracingOpts.Endpoint != "" {
+		shutdown, err := tracing.Setup(context.Background(), tracingOpts)
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			os.Exit(1)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdown(ctx); err != nil {
+				setupLog.Error(err, "unable to flush traces")
+			}
+		}()
+	}
+
+	k8sClient := mgr.GetClient()
+	apiReader := mgr.GetAPIReader()
+	if tracingOpts.Endpoint != "" {
+		k8sClient = tracing.WrapClient(k8sClient)
+		apiReader = tracing.WrapReader(apiReader, mgr.GetScheme())
+	}
+
+	reconciler := &controller.NamespaceLabelReconciler{
+		Client:          k8sClient,
+		Scheme:          mgr.GetScheme(),
+		Policy:          settings.Policy,
+		Recorder:        mgr.GetEventRecorderFor("namespacelabel-controller"),
+		DryRun:          dryRun,
+		WatchNamespaces: namespaces,
+		Namespaces:      namespaceFilter,
+		Limits:          settings.Limits,
+		Progress:        &health.Progress{},
+		APIReader:       apiReader,
+	}
+	if settings.AuditLogPath != "" {
+		sink, closer, err := audit.Open(settings.AuditLogPath)
+		if err != nil {
+			setupLog.Error(err, "unable to open audit log", "path", settings.AuditLogPath)
+			os.Exit(1)
+		}
+		defer closer.Close() //nolint:errcheck
+		reconciler.Audit = sink
+	}
+	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NamespaceLabel")
 		os.Exit(1)
 	}
+	ctrlmetrics.Registry.MustRegister(metrics.NewReadyCollector(mgr.GetCache()))
+	var defaulter *webhookv1.NamespaceCustomDefaulter
+	var quotaValidator *webhookv1alpha1.NamespaceLabelCustomValidator
+	if enableWebhooks {
+		namespace, err := managerNamespace()
+		if err != nil {
+			setupLog.Error(err, "unable to determine the manager namespace")
+			os.Exit(1)
+		}
+		if webhookCertRotation {
+			rotator := &certs.Rotator{
+				Client:  mgr.GetClient(),
+				Reader:  mgr.GetAPIReader(),
+				Secret:  types.NamespacedName{Namespace: namespace, Name: webhookCertSecret},
+				Service: types.NamespacedName{Namespace: namespace, Name: webhookService},
+				CertDir: webhookCertDir,
+			}
+			// The webhook server reads the certificate as soon as the manager
+			// starts, so it must exist before then.
+			if err := rotator.Ensure(context.Background()); err != nil {
+				setupLog.Error(err, "unable to provision the webhook certificates")
+				os.Exit(1)
+			}
+			if err := mgr.Add(rotator); err != nil {
+				setupLog.Error(err, "unable to set up the webhook certificate rotator")
+				os.Exit(1)
+			}
+		}
+
+		validator := &webhookv1.NamespaceCustomValidator{
+			Client:        mgr.GetClient(),
+			AllowedUsers:  []string{serviceAccountUsername(namespace)},
+			AllowedGroups: splitList(labelAdminGroups),
+		}
+		defaulter = &webhookv1.NamespaceCustomDefaulter{Namespaces: namespaceFilter, DryRun: dryRun}
+		defaulter.SetLabels(settings.DefaultLabels)
+		if err := webhookv1.SetupNamespaceWebhookWithManager(mgr, defaulter, validator); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Namespace")
+			os.Exit(1)
+		}
+		// Quota checks read the namespace uncached, so a burst of creates
+		// sees the labels the controller just wrote.
+		quotaValidator = &webhookv1alpha1.NamespaceLabelCustomValidator{Client: apiReader}
+		quotaValidator.SetPolicy(settings.Policy)
+		if err := webhookv1alpha1.SetupNamespaceLabelWebhookWithManager(mgr, quotaValidator); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "NamespaceLabel")
+			os.Exit(1)
+		}
+		if err := (&controller.DefaultsReconciler{
+			Client: k8sClient,
+			Name:   settings.DefaultsName,
+			DryRun: dryRun,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "NamespaceDefaults")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
+	if configPath != "" {
+		watcher := &config.Watcher{
+			Path: configPath,
+			OnChange: func(ctx context.Context, cfg *configv1alpha1.ManagerConfig) {
+				if changed := config.RestartRequired(managerConfig, cfg); len(changed) > 0 {
+					setupLog.Info("configuration changes take effect after a restart", "fields", changed)
+				}
+				next := flagSettings
+				config.Apply(cfg, &next)
+				// Webhooks serve on every replica, so they are updated first.
+				if defaulter != nil {
+					defaulter.SetLabels(next.DefaultLabels)
+				}
+				if quotaValidator != nil {
+					quotaValidator.SetPolicy(next.Policy)
+				}
+				reconciler.Reload(next.Policy, next.Limits)
+			},
+		}
+		if err := mgr.Add(watcher); err != nil {
+			setupLog.Error(err, "unable to watch the configuration file")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
+	stalled := reconciler.Progress.Stalled(stallTimeout,
+		health.QueueDepth(ctrlmetrics.Registry, controller.ControllerName))
+	if err := mgr.AddHealthzCheck("reconcile", stalled); err != nil {
+		setupLog.Error(err, "unable to set up health check")
+		os.Exit(1)
+	}
 	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	caches := append([]cache.Cache{mgr.GetCache()}, reconciler.NamespaceCaches()...)
+	if err := mgr.AddReadyzCheck("informers", health.CacheSynced(caches...)); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		addr := net.JoinHostPort("localhost", strconv.Itoa(webhook.DefaultPort))
+		if err := mgr.AddReadyzCheck("webhook", health.WebhookServing(addr)); err != nil {
+			setupLog.Error(err, "unable to set up ready check")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -166,3 +454,37 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// managerNamespace returns the namespace the manager runs in, from the
+// POD_NAMESPACE environment variable or the service account mount.
+func managerNamespace() (string, error) {
+	if ns := os.Getenv("POD_NAMESPACE"); ns != "" {
+		return ns, nil
+	}
+	ns, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	if err != nil {
+		return "", fmt.Errorf("POD_NAMESPACE is not set: %w", err)
+	}
+	return strings.TrimSpace(string(ns)), nil
+}
+
+// serviceAccountUsername returns the username the manager authenticates as,
+// from the POD_SERVICE_ACCOUNT environment variable.
+func serviceAccountUsername(namespace string) string {
+	name := os.Getenv("POD_SERVICE_ACCOUNT")
+	if name == "" {
+		name = "nsl-operator-tal-controller-manager"
+	}
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name)
+}
+
+// splitList returns the non-empty, trimmed elements of a comma-separated list.
+func splitList(list string) []string {
+	var out []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
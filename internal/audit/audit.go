@@ -0,0 +1,208 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit records every namespace label mutation made by the operator
+// as a JSON line, separate from the controller log. Each line carries the
+// SHA-256 of the previous one so removed or edited lines break the chain.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reasons a label was mutated.
+const (
+	ReasonAdded          = "Added"
+	ReasonUpdated        = "Updated"
+	ReasonDriftCorrected = "DriftCorrected"
+	ReasonRemoved        = "Removed"
+	ReasonFinalized      = "Finalized"
+)
+
+// Event is a single label mutation on a namespace.
+type Event struct {
+	Timestamp      time.Time `json:"timestamp"`
+	Namespace      string    `json:"namespace"`
+	NamespaceLabel string    `json:"namespaceLabel"`
+	Key            string    `json:"key"`
+	// OldValue is nil when the label was added.
+	OldValue *string `json:"oldValue"`
+	// NewValue is nil when the label was removed.
+	NewValue *string `json:"newValue"`
+	User     string  `json:"user,omitempty"`
+	Reason   string  `json:"reason"`
+}
+
+// Sink receives audit events.
+type Sink interface {
+	Record(events ...Event) error
+}
+
+// Genesis is the previous hash of the first record of a chain.
+const Genesis = ""
+
+// record is the on-disk form of an Event.
+type record struct {
+	Event
+	PrevHash string `json:"prevHash"`
+	Hash     string `json:"hash"`
+}
+
+// JSONSink writes events as hash-chained JSON lines.
+type JSONSink struct {
+	mu       sync.Mutex
+	w        io.Writer
+	lastHash string
+}
+
+// NewJSONSink returns a sink writing to w, chaining from lastHash. Genesis
+// starts a new chain.
+func NewJSONSink(w io.Writer, lastHash string) *JSONSink {
+	return &JSONSink{w: w, lastHash: lastHash}
+}
+
+// Open returns a sink for path. "-" writes to stdout; any other value is
+// opened for append and the chain resumes from its last line.
+func Open(path string) (*JSONSink, io.Closer, error) {
+	if path == "-" {
+		return NewJSONSink(os.Stdout, Genesis), io.NopCloser(nil), nil
+	}
+
+	lastHash, err := lastHashOf(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewJSONSink(f, lastHash), f, nil
+}
+
+// Record implements Sink.
+func (s *JSONSink) Record(events ...Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var buf bytes.Buffer
+	prev := s.lastHash
+	for _, e := range events {
+		line, hash, err := encode(e, prev)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+		prev = hash
+	}
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	s.lastHash = prev
+	return nil
+}
+
+// encode returns the JSON line for e chained after prevHash, and its hash.
+func encode(e Event, prevHash string) ([]byte, string, error) {
+	r := record{Event: e, PrevHash: prevHash}
+	unsigned, err := json.Marshal(r)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(unsigned)
+	r.Hash = hex.EncodeToString(sum[:])
+	line, err := json.Marshal(r)
+	return line, r.Hash, err
+}
+
+// Verify reads a log written by JSONSink and returns an error naming the
+// first line whose hash or chain link does not match. The first line must
+// start the chain, so removing leading lines is detected too.
+func Verify(r io.Reader) error {
+	return VerifyFrom(r, Genesis)
+}
+
+// VerifyFrom is Verify for a log whose first line follows the record with
+// hash checkpoint, such as the part of a log kept after older lines were
+// archived.
+func VerifyFrom(r io.Reader, checkpoint string) error {
+	scanner := bufio.NewScanner(r)
+	prev := checkpoint
+	for n := 1; scanner.Scan(); n++ {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		if rec.PrevHash != prev {
+			if prev == Genesis {
+				return fmt.Errorf("line %d: chain broken, expected the start of the chain", n)
+			}
+			return fmt.Errorf("line %d: chain broken, expected previous hash %s", n, prev)
+		}
+		_, hash, err := encode(rec.Event, rec.PrevHash)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		if hash != rec.Hash {
+			return fmt.Errorf("line %d: hash mismatch", n)
+		}
+		prev = rec.Hash
+	}
+	return scanner.Err()
+}
+
+// lastHashOf returns the hash of the last line in path, or Genesis if the
+// file does not exist or is empty.
+func lastHashOf(path string) (string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Genesis, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() //nolint:errcheck
+
+	var last []byte
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) > 0 {
+			last = append(last[:0], scanner.Bytes()...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if last == nil {
+		return Genesis, nil
+	}
+
+	var rec record
+	if err := json.Unmarshal(last, &rec); err != nil {
+		return "", fmt.Errorf("reading last audit record of %s: %w", path, err)
+	}
+	return rec.Hash, nil
+}
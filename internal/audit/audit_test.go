@@ -0,0 +1,121 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("JSONSink", func() {
+	value := "platform"
+	event := Event{
+		Timestamp:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Namespace:      "team-a",
+		NamespaceLabel: "labels",
+		Key:            "team",
+		NewValue:       &value,
+		Reason:         ReasonAdded,
+	}
+
+	It("writes one verifiable line per event", func() {
+		var buf bytes.Buffer
+		sink := NewJSONSink(&buf, "")
+		Expect(sink.Record(event, event)).To(Succeed())
+		Expect(sink.Record(event)).To(Succeed())
+
+		Expect(strings.Count(buf.String(), "\n")).To(Equal(3))
+		Expect(buf.String()).To(ContainSubstring(`"oldValue":null`))
+		Expect(Verify(&buf)).To(Succeed())
+	})
+
+	It("detects edited and removed lines", func() {
+		var buf bytes.Buffer
+		Expect(NewJSONSink(&buf, "").Record(event, event, event)).To(Succeed())
+		lines := strings.SplitAfter(buf.String(), "\n")
+
+		edited := strings.Replace(buf.String(), `"newValue":"platform"`, `"newValue":"other"`, 1)
+		Expect(Verify(strings.NewReader(edited))).To(MatchError(ContainSubstring("line 1")))
+
+		removed := lines[0] + lines[2]
+		Expect(Verify(strings.NewReader(removed))).To(MatchError(ContainSubstring("chain broken")))
+	})
+
+	It("detects removed leading lines unless verifying from a checkpoint", func() {
+		var buf bytes.Buffer
+		Expect(NewJSONSink(&buf, Genesis).Record(event, event, event)).To(Succeed())
+		lines := strings.SplitAfter(buf.String(), "\n")
+
+		headless := lines[1] + lines[2]
+		Expect(Verify(strings.NewReader(headless))).To(MatchError(
+			"line 1: chain broken, expected the start of the chain"))
+
+		var first record
+		Expect(json.Unmarshal([]byte(lines[0]), &first)).To(Succeed())
+		Expect(VerifyFrom(strings.NewReader(headless), first.Hash)).To(Succeed())
+		Expect(VerifyFrom(strings.NewReader(lines[2]), first.Hash)).To(MatchError(ContainSubstring(
+			"line 1: chain broken, expected previous hash " + first.Hash)))
+	})
+
+	It("resumes the chain when reopening a file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "audit.log")
+
+		sink, closer, err := Open(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sink.Record(event)).To(Succeed())
+		Expect(closer.Close()).To(Succeed())
+
+		sink, closer, err = Open(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sink.Record(event)).To(Succeed())
+		Expect(closer.Close()).To(Succeed())
+
+		f, err := os.Open(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer f.Close() //nolint:errcheck
+		Expect(Verify(f)).To(Succeed())
+	})
+})
+
+var _ = Describe("RequestedBy", func() {
+	spec := &metav1.FieldsV1{Raw: []byte(`{"f:spec":{"f:labels":{}}}`)}
+	status := &metav1.FieldsV1{Raw: []byte(`{"f:status":{}}`)}
+	older := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	newer := metav1.NewTime(older.Add(time.Hour))
+
+	It("prefers the annotation", func() {
+		obj := &metav1.ObjectMeta{Annotations: map[string]string{RequestedByAnnotation: "alice"}}
+		Expect(RequestedBy(obj)).To(Equal("alice"))
+	})
+
+	It("falls back to the latest manager of the spec", func() {
+		obj := &metav1.ObjectMeta{ManagedFields: []metav1.ManagedFieldsEntry{
+			{Manager: "kubectl", Time: &older, FieldsV1: spec},
+			{Manager: "argocd", Time: &newer, FieldsV1: spec},
+			{Manager: "manager", Time: &newer, FieldsV1: status, Subresource: "status"},
+		}}
+		Expect(RequestedBy(obj)).To(Equal("argocd"))
+	})
+})
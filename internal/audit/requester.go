@@ -0,0 +1,63 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RequestedByAnnotation may be set on a NamespaceLabel to name the user the
+// change is made on behalf of, e.g. by a GitOps pipeline.
+const RequestedByAnnotation = "dana.io.namespacelabel.com/requested-by"
+
+// RequestedBy returns who requested the current state of obj. The
+// RequestedByAnnotation wins; otherwise the field manager that most recently
+// wrote the spec is used.
+func RequestedBy(obj metav1.Object) string {
+	if user := obj.GetAnnotations()[RequestedByAnnotation]; user != "" {
+		return user
+	}
+
+	var (
+		manager string
+		latest  *metav1.Time
+	)
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Subresource != "" || entry.FieldsV1 == nil {
+			continue
+		}
+		if !ownsSpec(entry.FieldsV1.Raw) {
+			continue
+		}
+		if latest == nil || (entry.Time != nil && latest.Before(entry.Time)) {
+			manager, latest = entry.Manager, entry.Time
+		}
+	}
+	return manager
+}
+
+// ownsSpec reports whether a managedFields entry covers any spec field.
+func ownsSpec(raw []byte) bool {
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return false
+	}
+	_, ok := fields["f:spec"]
+	return ok
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package certs provisions the webhook server's serving certificate without
+// cert-manager: it keeps a self-signed CA and a serving certificate in a
+// Secret, writes them where the webhook server reads them and injects the CA
+// into every webhook configuration that calls the webhook service.
+package certs
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+)
+
+// Keys of the certificate Secret. CAKey holds a bundle: the current CA
+// first, then earlier CAs that are still valid, so connections verified
+// against either keep working while a rotation rolls out.
+const (
+	CAKey      = "ca.crt"
+	CAKeyKey   = "ca.key"
+	CertKey    = "tls.crt"
+	PrivateKey = "tls.key"
+)
+
+// pair is a certificate and its private key.
+type pair struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// newCA returns a self-signed CA valid from now for validity.
+func newCA(now time.Time, validity time.Duration) (pair, error) {
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: fmt.Sprintf("namespacelabel-webhook-ca@%d", now.Unix())},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	return issue(template, nil)
+}
+
+// newServing returns a serving certificate for dnsNames signed by ca.
+func newServing(ca pair, dnsNames []string, now time.Time, validity time.Duration) (pair, error) {
+	notAfter := now.Add(validity)
+	if notAfter.After(ca.cert.NotAfter) {
+		notAfter = ca.cert.NotAfter
+	}
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:    dnsNames,
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	return issue(template, &ca)
+}
+
+// issue creates a key and signs template with parent, or with the new key
+// itself when parent is nil.
+func issue(template *x509.Certificate, parent *pair) (pair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return pair{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return pair{}, err
+	}
+	template.SerialNumber = serial
+
+	signerCert, signerKey := template, crypto.Signer(key)
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, key.Public(), signerKey)
+	if err != nil {
+		return pair{}, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return pair{}, err
+	}
+	return pair{cert: cert, key: key}, nil
+}
+
+// encode returns the PEM encoding of the certificate and the key.
+func (p pair) encode() (certPEM, keyPEM []byte, err error) {
+	der, err := x509.MarshalPKCS8PrivateKey(p.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encodeCerts(p.cert), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// decode parses a PEM certificate and key. Only the first certificate of
+// certPEM is used.
+func decode(certPEM, keyPEM []byte) (pair, error) {
+	certs, err := decodeCerts(certPEM)
+	if err != nil {
+		return pair{}, err
+	}
+	if len(certs) == 0 {
+		return pair{}, fmt.Errorf("no certificate")
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return pair{}, fmt.Errorf("no private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return pair{}, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return pair{}, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return pair{cert: certs[0], key: signer}, nil
+}
+
+func encodeCerts(certs ...*x509.Certificate) []byte {
+	var buf bytes.Buffer
+	for _, cert := range certs {
+		_ = pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return buf.Bytes()
+}
+
+func decodeCerts(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+}
+
+// signedBy reports whether cert was issued by ca and is valid for dnsNames.
+func signedBy(cert *x509.Certificate, ca *x509.Certificate, dnsNames []string) bool {
+	if cert.CheckSignatureFrom(ca) != nil {
+		return false
+	}
+	return slices.Equal(cert.DNSNames, dnsNames)
+}
@@ -0,0 +1,336 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Defaults of the Rotator's validity and rotation settings.
+const (
+	DefaultCAValidity   = 10 * 365 * 24 * time.Hour
+	DefaultValidity     = 365 * 24 * time.Hour
+	DefaultRotateBefore = 30 * 24 * time.Hour
+	DefaultInterval     = 10 * time.Minute
+)
+
+// +kubebuilder:rbac:groups="",namespace=system,resources=secrets,verbs=get;create;update
+// +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=validatingwebhookconfigurations;mutatingwebhookconfigurations,verbs=get;list;patch
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;patch
+
+// Rotator keeps the webhook serving certificate valid. Every replica runs
+// one: the Secret is shared, so all replicas serve certificates from the
+// same CA, while each writes its own copy to CertDir.
+type Rotator struct {
+	// Client writes the Secret and the webhook configurations.
+	Client client.Client
+	// Reader reads them. It must not be a cache, since Ensure runs before
+	// the manager starts.
+	Reader client.Reader
+
+	// Secret stores the CA and the serving certificate.
+	Secret types.NamespacedName
+	// Service is the webhook Service. The serving certificate is issued for
+	// its DNS names, and the CA is injected into every webhook that calls it.
+	Service types.NamespacedName
+	// CertDir is where the webhook server reads tls.crt and tls.key.
+	CertDir string
+
+	// CAValidity and Validity are the lifetimes of new CA and serving
+	// certificates. The CA is replaced once it would expire before a new
+	// serving certificate, and the serving certificate RotateBefore its
+	// expiry. Zero values use the defaults.
+	CAValidity   time.Duration
+	Validity     time.Duration
+	RotateBefore time.Duration
+	// Interval is how often Start checks the certificates and the webhook
+	// configurations.
+	Interval time.Duration
+
+	now func() time.Time
+}
+
+// Start implements manager.Runnable. It calls Ensure every Interval, so the
+// certificates are rotated before they expire and recreated webhook
+// configurations get the CA again.
+func (r *Rotator) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("cert-rotator")
+	ticker := time.NewTicker(durationOr(r.Interval, DefaultInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := r.Ensure(ctx); err != nil {
+				logger.Error(err, "unable to refresh the webhook certificates")
+			}
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every
+// replica serves webhooks, so every replica needs the certificates.
+func (r *Rotator) NeedLeaderElection() bool {
+	return false
+}
+
+// Ensure creates or rotates the certificates in the Secret, writes the
+// serving certificate to CertDir and injects the CA bundle into the webhook
+// configurations and CRD conversion webhooks calling Service.
+func (r *Rotator) Ensure(ctx context.Context) error {
+	data, err := r.refreshSecret(ctx)
+	if err != nil {
+		return fmt.Errorf("refreshing secret %s: %w", r.Secret, err)
+	}
+	if err := r.writeFiles(data); err != nil {
+		return fmt.Errorf("writing certificates to %s: %w", r.CertDir, err)
+	}
+	return r.inject(ctx, data[CAKey])
+}
+
+// DNSNames returns the names the serving certificate is issued for.
+func (r *Rotator) DNSNames() []string {
+	svc := fmt.Sprintf("%s.%s.svc", r.Service.Name, r.Service.Namespace)
+	return []string{svc, svc + ".cluster.local"}
+}
+
+// refreshSecret returns the Secret's data after replacing missing, invalid
+// or expiring certificates. When another replica updates the Secret first,
+// its certificates are used instead.
+func (r *Rotator) refreshSecret(ctx context.Context) (map[string][]byte, error) {
+	var data map[string][]byte
+	conflict := func(err error) bool { return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) }
+	err := retry.OnError(retry.DefaultRetry, conflict, func() error {
+		var secret corev1.Secret
+		err := r.Reader.Get(ctx, r.Secret, &secret)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		exists := err == nil
+
+		next, changed, err := r.refresh(secret.Data)
+		if err != nil {
+			return err
+		}
+		data = next
+		switch {
+		case !changed:
+			return nil
+		case exists:
+			secret.Data = next
+			return r.Client.Update(ctx, &secret)
+		}
+		secret = corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: r.Secret.Namespace, Name: r.Secret.Name},
+			Type:       corev1.SecretTypeTLS,
+			Data:       next,
+		}
+		return r.Client.Create(ctx, &secret)
+	})
+	return data, err
+}
+
+// refresh returns data with a valid CA and serving certificate, and whether
+// anything had to change.
+func (r *Rotator) refresh(data map[string][]byte) (map[string][]byte, bool, error) {
+	now := r.clock()
+	validity := durationOr(r.Validity, DefaultValidity)
+	dnsNames := r.DNSNames()
+
+	ca, err := decode(data[CAKey], data[CAKeyKey])
+	rotateCA := err != nil || !ca.cert.IsCA || now.Add(validity).After(ca.cert.NotAfter)
+	if rotateCA {
+		if ca, err = newCA(now, durationOr(r.CAValidity, DefaultCAValidity)); err != nil {
+			return nil, false, err
+		}
+	}
+
+	serving, err := decode(data[CertKey], data[PrivateKey])
+	if rotateCA || err != nil || !signedBy(serving.cert, ca.cert, dnsNames) ||
+		now.Add(durationOr(r.RotateBefore, DefaultRotateBefore)).After(serving.cert.NotAfter) {
+		if serving, err = newServing(ca, dnsNames, now, validity); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// Keep earlier CAs in the bundle until they expire, so the serving
+	// certificate they signed stays trusted until every replica reloads.
+	bundle := []*x509.Certificate{ca.cert}
+	previous, _ := decodeCerts(data[CAKey])
+	for _, cert := range previous {
+		if !cert.Equal(ca.cert) && now.Before(cert.NotAfter) {
+			bundle = append(bundle, cert)
+		}
+	}
+
+	next := map[string][]byte{CAKey: encodeCerts(bundle...)}
+	if _, next[CAKeyKey], err = ca.encode(); err != nil {
+		return nil, false, err
+	}
+	if next[CertKey], next[PrivateKey], err = serving.encode(); err != nil {
+		return nil, false, err
+	}
+	changed := false
+	for key, value := range next {
+		if !bytes.Equal(data[key], value) {
+			changed = true
+		}
+	}
+	return next, changed, nil
+}
+
+// writeFiles writes the serving certificate and key to CertDir, replacing
+// each file atomically so the webhook server never reads a partial one.
+func (r *Rotator) writeFiles(data map[string][]byte) error {
+	if err := os.MkdirAll(r.CertDir, 0o700); err != nil {
+		return err
+	}
+	for _, name := range []string{PrivateKey, CertKey} {
+		path := filepath.Join(r.CertDir, name)
+		if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data[name]) {
+			continue
+		}
+		tmp, err := os.CreateTemp(r.CertDir, "."+name)
+		if err != nil {
+			return err
+		}
+		_, err = tmp.Write(data[name])
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), path)
+		}
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+			return err
+		}
+	}
+	return nil
+}
+
+// inject sets caBundle on every webhook calling Service.
+func (r *Rotator) inject(ctx context.Context, caBundle []byte) error {
+	var validating admissionregistrationv1.ValidatingWebhookConfigurationList
+	if err := r.Reader.List(ctx, &validating); err != nil {
+		return err
+	}
+	for i := range validating.Items {
+		cfg := &validating.Items[i]
+		patch := client.MergeFromWithOptions(cfg.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		changed := false
+		for j := range cfg.Webhooks {
+			changed = r.setCABundle(cfg.Webhooks[j].ClientConfig.Service, &cfg.Webhooks[j].ClientConfig.CABundle,
+				caBundle) || changed
+		}
+		if err := r.patch(ctx, cfg, patch, changed); err != nil {
+			return err
+		}
+	}
+
+	var mutating admissionregistrationv1.MutatingWebhookConfigurationList
+	if err := r.Reader.List(ctx, &mutating); err != nil {
+		return err
+	}
+	for i := range mutating.Items {
+		cfg := &mutating.Items[i]
+		patch := client.MergeFromWithOptions(cfg.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		changed := false
+		for j := range cfg.Webhooks {
+			changed = r.setCABundle(cfg.Webhooks[j].ClientConfig.Service, &cfg.Webhooks[j].ClientConfig.CABundle,
+				caBundle) || changed
+		}
+		if err := r.patch(ctx, cfg, patch, changed); err != nil {
+			return err
+		}
+	}
+
+	var crds apiextensionsv1.CustomResourceDefinitionList
+	if err := r.Reader.List(ctx, &crds); err != nil {
+		return err
+	}
+	for i := range crds.Items {
+		crd := &crds.Items[i]
+		conversion := crd.Spec.Conversion
+		if conversion == nil || conversion.Webhook == nil || conversion.Webhook.ClientConfig == nil ||
+			conversion.Webhook.ClientConfig.Service == nil {
+			continue
+		}
+		patch := client.MergeFromWithOptions(crd.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		clientConfig := conversion.Webhook.ClientConfig
+		ref := &admissionregistrationv1.ServiceReference{
+			Namespace: clientConfig.Service.Namespace,
+			Name:      clientConfig.Service.Name,
+		}
+		if err := r.patch(ctx, crd, patch, r.setCABundle(ref, &clientConfig.CABundle, caBundle)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setCABundle sets *target to caBundle if svc is the webhook Service, and
+// reports whether that changed it.
+func (r *Rotator) setCABundle(svc *admissionregistrationv1.ServiceReference, target *[]byte, caBundle []byte) bool {
+	if svc == nil || svc.Namespace != r.Service.Namespace || svc.Name != r.Service.Name ||
+		bytes.Equal(*target, caBundle) {
+		return false
+	}
+	*target = caBundle
+	return true
+}
+
+func (r *Rotator) patch(ctx context.Context, obj client.Object, patch client.Patch, changed bool) error {
+	if !changed {
+		return nil
+	}
+	if err := r.Client.Patch(ctx, obj, patch); err != nil {
+		return fmt.Errorf("injecting the CA bundle into %s: %w", obj.GetName(), err)
+	}
+	return nil
+}
+
+func (r *Rotator) clock() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+func durationOr(d, fallback time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return fallback
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certs
+
+import (
+	"context"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Rotator", func() {
+	var (
+		ctx     context.Context
+		c       client.Client
+		rotator *Rotator
+		now     time.Time
+	)
+
+	service := types.NamespacedName{Namespace: "nsl-system", Name: "webhook-service"}
+	webhookFor := func(svc types.NamespacedName) admissionregistrationv1.ValidatingWebhook {
+		return admissionregistrationv1.ValidatingWebhook{
+			Name: "vnamespace.kb.io",
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service: &admissionregistrationv1.ServiceReference{Namespace: svc.Namespace, Name: svc.Name},
+			},
+		}
+	}
+	secret := func() *corev1.Secret {
+		var s corev1.Secret
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "nsl-system", Name: "webhook-cert"}, &s)).To(Succeed())
+		return &s
+	}
+	bundle := func() []*x509.Certificate {
+		certs, err := decodeCerts(secret().Data[CAKey])
+		Expect(err).NotTo(HaveOccurred())
+		return certs
+	}
+	servingCert := func() *x509.Certificate {
+		p, err := decode(secret().Data[CertKey], secret().Data[PrivateKey])
+		Expect(err).NotTo(HaveOccurred())
+		return p.cert
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(apiextensionsv1.AddToScheme(scheme)).To(Succeed())
+
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&admissionregistrationv1.ValidatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: "ours"},
+				Webhooks:   []admissionregistrationv1.ValidatingWebhook{webhookFor(service)},
+			},
+			&admissionregistrationv1.ValidatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: "theirs"},
+				Webhooks: []admissionregistrationv1.ValidatingWebhook{
+					webhookFor(types.NamespacedName{Namespace: "other", Name: "webhook-service"}),
+				},
+			},
+			&apiextensionsv1.CustomResourceDefinition{
+				ObjectMeta: metav1.ObjectMeta{Name: "namespacelabels.dana.io.namespacelabel.com"},
+				Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+					Conversion: &apiextensionsv1.CustomResourceConversion{
+						Strategy: apiextensionsv1.WebhookConverter,
+						Webhook: &apiextensionsv1.WebhookConversion{
+							ClientConfig: &apiextensionsv1.WebhookClientConfig{
+								Service: &apiextensionsv1.ServiceReference{
+									Namespace: service.Namespace,
+									Name:      service.Name,
+								},
+							},
+						},
+					},
+				},
+			},
+		).Build()
+
+		now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		rotator = &Rotator{
+			Client:  c,
+			Reader:  c,
+			Secret:  types.NamespacedName{Namespace: "nsl-system", Name: "webhook-cert"},
+			Service: service,
+			CertDir: GinkgoT().TempDir(),
+			now:     func() time.Time { return now },
+		}
+	})
+
+	It("issues a serving certificate and injects its CA into webhooks calling the service", func() {
+		Expect(rotator.Ensure(ctx)).To(Succeed())
+
+		s := secret()
+		Expect(s.Type).To(Equal(corev1.SecretTypeTLS))
+		cert := servingCert()
+		Expect(cert.DNSNames).To(Equal([]string{
+			"webhook-service.nsl-system.svc",
+			"webhook-service.nsl-system.svc.cluster.local",
+		}))
+		Expect(cert.CheckSignatureFrom(bundle()[0])).To(Succeed())
+
+		for _, name := range []string{CertKey, PrivateKey} {
+			data, err := os.ReadFile(filepath.Join(rotator.CertDir, name))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(Equal(s.Data[name]))
+		}
+
+		var ours, theirs admissionregistrationv1.ValidatingWebhookConfiguration
+		Expect(c.Get(ctx, types.NamespacedName{Name: "ours"}, &ours)).To(Succeed())
+		Expect(c.Get(ctx, types.NamespacedName{Name: "theirs"}, &theirs)).To(Succeed())
+		Expect(ours.Webhooks[0].ClientConfig.CABundle).To(Equal(s.Data[CAKey]))
+		Expect(theirs.Webhooks[0].ClientConfig.CABundle).To(BeEmpty())
+
+		var crd apiextensionsv1.CustomResourceDefinition
+		Expect(c.Get(ctx, types.NamespacedName{Name: "namespacelabels.dana.io.namespacelabel.com"}, &crd)).To(Succeed())
+		Expect(crd.Spec.Conversion.Webhook.ClientConfig.CABundle).To(Equal(s.Data[CAKey]))
+	})
+
+	It("leaves valid certificates alone", func() {
+		Expect(rotator.Ensure(ctx)).To(Succeed())
+		version := secret().ResourceVersion
+
+		now = now.Add(30 * 24 * time.Hour)
+		Expect(rotator.Ensure(ctx)).To(Succeed())
+		Expect(secret().ResourceVersion).To(Equal(version))
+	})
+
+	It("reissues the serving certificate from the same CA before it expires", func() {
+		Expect(rotator.Ensure(ctx)).To(Succeed())
+		ca, cert := bundle()[0], servingCert()
+
+		now = cert.NotAfter.Add(-DefaultRotateBefore + time.Hour)
+		Expect(rotator.Ensure(ctx)).To(Succeed())
+		Expect(servingCert().SerialNumber).NotTo(Equal(cert.SerialNumber))
+		Expect(bundle()).To(HaveLen(1))
+		Expect(bundle()[0].Equal(ca)).To(BeTrue())
+	})
+
+	It("keeps the previous CA in the bundle after replacing an expiring CA", func() {
+		Expect(rotator.Ensure(ctx)).To(Succeed())
+		old := bundle()[0]
+
+		now = old.NotAfter.Add(-DefaultValidity + time.Hour)
+		Expect(rotator.Ensure(ctx)).To(Succeed())
+		certs := bundle()
+		Expect(certs).To(HaveLen(2))
+		Expect(certs[0].Equal(old)).To(BeFalse())
+		Expect(certs[1].Equal(old)).To(BeTrue())
+		Expect(servingCert().CheckSignatureFrom(certs[0])).To(Succeed())
+
+		now = old.NotAfter.Add(time.Hour)
+		Expect(rotator.Ensure(ctx)).To(Succeed())
+		Expect(bundle()).To(HaveLen(1))
+	})
+
+	It("reissues the serving certificate when the service changes", func() {
+		Expect(rotator.Ensure(ctx)).To(Succeed())
+
+		rotator.Service.Name = "renamed"
+		Expect(rotator.Ensure(ctx)).To(Succeed())
+		Expect(servingCert().DNSNames).To(ContainElement("renamed.nsl-system.svc"))
+	})
+})
@@ -0,0 +1,386 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+var _ = Describe("kubectl-nslabel", func() {
+	const namespace = "team-a"
+
+	var (
+		ctx context.Context
+		c   client.Client
+		out *bytes.Buffer
+	)
+
+	run := func(args ...string) error {
+		env := &Env{Client: c, Namespace: namespace, Policy: plan.DefaultPolicy(), Out: out}
+		cmd := newRootCommand(env)
+		cmd.SetArgs(args)
+		cmd.SetErr(&bytes.Buffer{})
+		return cmd.ExecuteContext(ctx)
+	}
+
+	get := func(name string) *danaiov1alpha1.NamespaceLabel {
+		nl := &danaiov1alpha1.NamespaceLabel{}
+		Expect(c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, nl)).To(Succeed())
+		return nl
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		out = &bytes.Buffer{}
+		c = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+					Name: namespace,
+					Labels: map[string]string{
+						"team":                        "platform",
+						"manual":                      "yes",
+						"kubernetes.io/metadata.name": namespace,
+					},
+				}},
+				&danaiov1alpha1.NamespaceLabel{
+					ObjectMeta: metav1.ObjectMeta{Name: "owners", Namespace: namespace},
+					Spec: danaiov1alpha1.NamespaceLabelSpec{Labels: map[string]string{
+						"team":                    "platform",
+						"node.kubernetes.io/role": "x",
+					}},
+					Status: danaiov1alpha1.NamespaceLabelStatus{
+						AppliedLabels: map[string]string{"team": "platform"},
+						RejectedLabels: []danaiov1alpha1.RejectedLabel{{
+							Key:     "node.kubernetes.io/role",
+							Reason:  danaiov1alpha1.RejectedReasonProtected,
+							Message: `keys under "kubernetes.io" are reserved`,
+						}},
+					},
+				},
+			).
+			WithStatusSubresource(&danaiov1alpha1.NamespaceLabel{}).
+			Build()
+	})
+
+	It("updates keys in the object requesting them and adds new ones to --name", func() {
+		Expect(run("set", "team=infra", "tier=gold", "--name", "extra")).To(Succeed())
+
+		Expect(get("owners").Spec.Labels).To(HaveKeyWithValue("team", "infra"))
+		Expect(get("extra").Spec.Labels).To(Equal(map[string]string{"tier": "gold"}))
+	})
+
+	It("refuses keys the policy would reject", func() {
+		Expect(run("set", "kubernetes.io/foo=bar")).To(MatchError(ContainSubstring("Protected")))
+		Expect(run("set", "team")).To(MatchError(ContainSubstring("KEY=VALUE")))
+	})
+
+	It("removes keys and deletes objects left empty", func() {
+		Expect(run("set", "tier=gold", "--name", "extra")).To(Succeed())
+		Expect(run("unset", "tier", "team")).To(Succeed())
+
+		Expect(get("owners").Spec.Labels).NotTo(HaveKey("team"))
+		err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: "extra"}, &danaiov1alpha1.NamespaceLabel{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		Expect(run("unset", "missing")).To(MatchError(ContainSubstring("no NamespaceLabel")))
+	})
+
+	It("lists requested labels with their state", func() {
+		Expect(run("list")).To(Succeed())
+		Expect(out.String()).To(MatchRegexp(`team\s+platform\s+owners\s+Applied`))
+		Expect(out.String()).To(MatchRegexp(`node.kubernetes.io/role\s+x\s+owners\s+Rejected \(Protected\)`))
+	})
+
+	It("shows the pending changes", func() {
+		Expect(run("set", "team=infra", "tier=gold")).To(Succeed())
+		out.Reset()
+
+		Expect(run("diff")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("namespacelabel/owners:\n~ team=platform -> infra"))
+		Expect(out.String()).To(ContainSubstring("! node.kubernetes.io/role: Protected"))
+	})
+
+	Context("with manifests", func() {
+		var dir string
+
+		write := func(name, content string) string {
+			path := filepath.Join(dir, name)
+			Expect(os.WriteFile(path, []byte(content), 0o600)).To(Succeed())
+			return path
+		}
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+			write("owners.yaml", `apiVersion: dana.io.namespacelabel.com/v1alpha1
+kind: NamespaceLabel
+metadata:
+  name: owners
+  namespace: team-a
+spec:
+  labels:
+    tier: gold
+---
+apiVersion: dana.io.namespacelabel.com/v1alpha1
+kind: NamespaceLabel
+metadata:
+  name: labels
+  namespace: team-b
+spec:
+  labels:
+    team: data
+`)
+			write("README.md", "not a manifest")
+		})
+
+		It("compares them with the live cluster", func() {
+			Expect(run("diff", "-f", dir)).To(Succeed())
+			Expect(out.String()).To(ContainSubstring(
+				"namespace/team-a:\n  namespacelabel/owners:\n  + tier=gold\n  - team=platform\n"))
+			Expect(out.String()).To(ContainSubstring(
+				"namespace/team-b (not found):\n  namespacelabel/labels:\n  + team=data\n"))
+		})
+
+		It("compares them with a snapshot", func() {
+			snapshot := write("snapshot.json", `{"apiVersion": "v1", "kind": "List", "items": [
+  {"apiVersion": "v1", "kind": "Namespace",
+   "metadata": {"name": "team-b", "labels": {"team": "data"}}},
+  {"apiVersion": "dana.io.namespacelabel.com/v1alpha1", "kind": "NamespaceLabel",
+   "metadata": {"name": "other", "namespace": "team-b"},
+   "spec": {"labels": {"team": "data"}},
+   "status": {"appliedLabels": {"team": "data"}}}
+]}`)
+			c = nil
+			Expect(run("diff", "-f", filepath.Join(dir, "owners.yaml"), "--snapshot", snapshot)).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("namespace/team-a (not found):\n  namespacelabel/owners:\n  + tier=gold\n"))
+			Expect(out.String()).To(ContainSubstring(
+				`  ! team: Conflict: key is owned by NamespaceLabel "other"`))
+		})
+
+		It("fails with --exit-code only when there are changes", func() {
+			Expect(run("diff", "-f", dir, "--exit-code")).To(MatchError(errChanges))
+
+			path := write("owners.yaml", `apiVersion: dana.io.namespacelabel.com/v1alpha1
+kind: NamespaceLabel
+metadata:
+  name: owners
+spec:
+  labels:
+    team: platform
+`)
+			out.Reset()
+			Expect(run("diff", "-f", path, "--exit-code")).To(Succeed())
+			Expect(out.String()).To(Equal("No changes.\n"))
+		})
+
+		It("skips the namespaces the manager does not handle", func() {
+			config := filepath.Join(GinkgoT().TempDir(), "manager-config.yaml")
+			Expect(os.WriteFile(config, []byte(`apiVersion: config.dana.io.namespacelabel.com/v1alpha1
+kind: ManagerConfig
+namespaces:
+  selector: team=data
+  exclude: [team-b]
+`), 0o600)).To(Succeed())
+			Expect(run("diff", "-f", dir, "--config", config, "--exit-code")).To(Succeed())
+			Expect(out.String()).To(Equal(`namespace/team-a skipped: does not match the namespace selector "team=data"
+namespace/team-b skipped: matches the excluded pattern "team-b"
+No changes.
+`))
+		})
+	})
+
+	Context("importing existing labels", func() {
+		BeforeEach(func() {
+			Expect(c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "team-b",
+				Labels: map[string]string{"cost-center": "42", "argocd.argoproj.io/instance": "b"},
+			}})).To(Succeed())
+			Expect(c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "kube-system",
+				Labels: map[string]string{"cost-center": "0"},
+			}})).To(Succeed())
+		})
+
+		It("prints NamespaceLabels adopting the unmanaged labels", func() {
+			Expect(run("import")).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("name: labels\n  namespace: team-a\n"))
+			Expect(out.String()).To(ContainSubstring("spec:\n  labels:\n    manual: \"yes\"\n"))
+			Expect(out.String()).NotTo(ContainSubstring("appliedLabels"))
+			Expect(out.String()).NotTo(ContainSubstring("metadata.name"))
+
+			err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: "labels"}, &danaiov1alpha1.NamespaceLabel{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("creates them as owned across namespaces", func() {
+			Expect(run("import", "-A", "--create", "--exclude", "argocd.argoproj.io/*")).To(Succeed())
+
+			nl := get("labels")
+			Expect(nl.Spec.Labels).To(Equal(map[string]string{"manual": "yes"}))
+			Expect(nl.Status.AppliedLabels).To(Equal(map[string]string{"manual": "yes"}))
+
+			other := &danaiov1alpha1.NamespaceLabel{}
+			Expect(c.Get(ctx, client.ObjectKey{Namespace: "team-b", Name: "labels"}, other)).To(Succeed())
+			Expect(other.Spec.Labels).To(Equal(map[string]string{"cost-center": "42"}))
+			Expect(other.Status.AppliedLabels).To(Equal(map[string]string{"cost-center": "42"}))
+
+			err := c.Get(ctx, client.ObjectKey{Namespace: "kube-system", Name: "labels"}, &danaiov1alpha1.NamespaceLabel{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+
+			By("leaving nothing for the operator to change")
+			out.Reset()
+			Expect(run("diff")).To(Succeed())
+			Expect(out.String()).NotTo(ContainSubstring("namespacelabel/labels"))
+		})
+	})
+
+	Context("reporting", func() {
+		changed := metav1.NewTime(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+		BeforeEach(func() {
+			Expect(c.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "team-b",
+				Labels: map[string]string{"owner": "alice"},
+				ManagedFields: []metav1.ManagedFieldsEntry{{
+					Manager:    "kubectl",
+					Operation:  metav1.ManagedFieldsOperationUpdate,
+					Time:       &changed,
+					FieldsType: "FieldsV1",
+					FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:owner":{}}}}`)},
+				}},
+			}})).To(Succeed())
+		})
+
+		It("writes CSV for the namespace", func() {
+			Expect(run("report")).To(Succeed())
+			Expect(out.String()).To(Equal("namespace,key,value,namespaceLabel,status,lastChanged\n" +
+				"team-a,kubernetes.io/metadata.name,team-a,,Unmanaged (Protected),\n" +
+				"team-a,manual,yes,,Unmanaged,\n" +
+				"team-a,node.kubernetes.io/role,x,owners,Rejected (Protected),\n" +
+				"team-a,team,platform,owners,Applied,\n"))
+		})
+
+		It("writes JSON and Markdown across namespaces", func() {
+			Expect(run("report", "-A", "--key", "owner", "-o", "json")).To(Succeed())
+			Expect(out.String()).To(MatchJSON(`[{"namespace": "team-b", "key": "owner", "value": "alice",
+				"status": "Unmanaged", "lastChanged": "2024-05-01T12:00:00Z"}]`))
+
+			out.Reset()
+			Expect(run("report", "-A", "--key", "owner", "-o", "markdown")).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("| team-b | owner | alice |  | Unmanaged | 2024-05-01T12:00:00Z |\n"))
+
+			Expect(run("report", "-o", "yaml")).To(MatchError(ContainSubstring("unknown output format")))
+		})
+	})
+
+	It("migrates deprecated keys to their replacements", func() {
+		Expect(run("migrate", "--rename", "team=dana.io/team", "--dry-run")).To(Succeed())
+		Expect(out.String()).To(Equal("namespacelabel/owners in team-a would move team -> dana.io/team\n"))
+		Expect(get("owners").Spec.Labels).To(HaveKey("team"))
+
+		out.Reset()
+		Expect(run("migrate", "--rename", "team=dana.io/team")).To(Succeed())
+		Expect(get("owners").Spec.Labels).To(Equal(map[string]string{
+			"dana.io/team":            "platform",
+			"node.kubernetes.io/role": "x",
+		}))
+
+		out.Reset()
+		Expect(run("migrate", "--rename", "team=dana.io/team")).To(Succeed())
+		Expect(out.String()).To(Equal("No NamespaceLabels request a deprecated key.\n"))
+
+		Expect(run("migrate")).To(MatchError(ContainSubstring("no renames given")))
+	})
+
+	It("uses the policy of the --config file in every subcommand", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "manager-config.yaml")
+		Expect(os.WriteFile(path, []byte(`apiVersion: config.dana.io.namespacelabel.com/v1alpha1
+kind: ManagerConfig
+policy:
+  protectedPrefixes: [example.com]
+  keyMigrations:
+  - from: team
+    to: dana.io/team
+`), 0o600)).To(Succeed())
+
+		Expect(run("explain", "example.com/tier", "--config", path)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("would be rejected: Protected"))
+
+		out.Reset()
+		Expect(run("--config", path, "set", "example.com/tier=gold")).To(MatchError(ContainSubstring("Protected")))
+
+		Expect(run("migrate", "--config", path, "--dry-run")).To(Succeed())
+		Expect(out.String()).To(Equal("namespacelabel/owners in team-a would move team -> dana.io/team\n"))
+
+		Expect(run("list", "--config", filepath.Join(GinkgoT().TempDir(), "missing.yaml"))).To(HaveOccurred())
+	})
+
+	It("explains rejected keys", func() {
+		Expect(run("explain", "node.kubernetes.io/role")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("was rejected: Protected"))
+
+		out.Reset()
+		Expect(run("explain", "k8s.io/other")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("would be rejected: Protected"))
+
+		out.Reset()
+		Expect(run("explain", "tier")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("would be accepted"))
+	})
+
+	It("explains Pod Security levels requested through spec.podSecurity", func() {
+		Expect(c.Create(ctx, &danaiov1alpha1.NamespaceLabel{
+			ObjectMeta: metav1.ObjectMeta{Name: "security", Namespace: namespace},
+			Spec: danaiov1alpha1.NamespaceLabelSpec{PodSecurity: &danaiov1alpha1.PodSecurity{
+				Enforce: &danaiov1alpha1.PodSecurityMode{Level: danaiov1alpha1.PodSecurityPrivileged},
+			}},
+		})).To(Succeed())
+
+		Expect(run("explain", "pod-security.kubernetes.io/enforce")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring(
+			`requested by namespacelabel/security will be rejected: TooPrivileged: level "privileged"`))
+
+		out.Reset()
+		Expect(run("explain", "pod-security.kubernetes.io/audit")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("would be rejected: Protected"))
+		Expect(out.String()).To(ContainSubstring("Set spec.podSecurity"))
+	})
+
+	It("reports who owns a key", func() {
+		Expect(run("who-owns", "team")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("team is owned by namespacelabel/owners"))
+
+		out.Reset()
+		Expect(run("who-owns", "manual")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("not managed by any NamespaceLabel"))
+	})
+})
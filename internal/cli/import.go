@@ -0,0 +1,251 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+// importOptions are the flags of the import command.
+type importOptions struct {
+	name              string
+	allNamespaces     bool
+	create            bool
+	excludeKeys       []string
+	excludeNamespaces []string
+}
+
+func newImportCommand(env *Env) *cobra.Command {
+	opts := importOptions{name: defaultObjectName}
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Adopt the labels already on namespaces into NamespaceLabels",
+		Long: "Adopt the labels already on namespaces into NamespaceLabels. Keys the policy\n" +
+			"rejects, keys matching --exclude and keys another NamespaceLabel requests are\n" +
+			"left alone. With --create the objects record the adopted labels as applied in\n" +
+			"their status, so the operator takes them over without changing the namespace.\n\n" +
+			"Without --create the objects are printed as YAML without a status, which\n" +
+			"kubectl apply would drop; the operator records the labels as applied on its\n" +
+			"first reconcile, since their values already match.",
+		Example: "  kubectl nslabel import -n team-a > team-a.yaml\n" +
+			"  kubectl nslabel import --all-namespaces --exclude 'argocd.argoproj.io/*' --create",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := validatePatterns(append(opts.excludeKeys, opts.excludeNamespaces...)); err != nil {
+				return err
+			}
+			return env.importLabels(cmd.Context(), opts)
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&opts.name, "name", opts.name, "The NamespaceLabel that receives the adopted labels.")
+	flags.BoolVarP(&opts.allNamespaces, "all-namespaces", "A", false, "Import the labels of every namespace.")
+	flags.BoolVar(&opts.create, "create", false, "Write the NamespaceLabels to the cluster instead of printing them.")
+	flags.StringSliceVar(&opts.excludeKeys, "exclude", nil, "Glob patterns of label keys to leave alone.")
+	flags.StringSliceVar(&opts.excludeNamespaces, "exclude-namespace", slices.Clone(plan.DefaultExcludedNamespaces),
+		"Glob patterns of namespaces skipped by --all-namespaces.")
+	return cmd
+}
+
+// importLabels adopts the labels of the selected namespaces.
+func (env *Env) importLabels(ctx context.Context, opts importOptions) error {
+	if err := env.ensureClient(); err != nil {
+		return err
+	}
+
+	var namespaces []corev1.Namespace
+	if opts.allNamespaces {
+		var list corev1.NamespaceList
+		if err := env.Client.List(ctx, &list); err != nil {
+			return fmt.Errorf("listing namespaces: %w", err)
+		}
+		for _, ns := range list.Items {
+			if !matchesAny(opts.excludeNamespaces, ns.Name) {
+				namespaces = append(namespaces, ns)
+			}
+		}
+	} else {
+		var ns corev1.Namespace
+		if err := env.Client.Get(ctx, client.ObjectKey{Name: env.Namespace}, &ns); err != nil {
+			return fmt.Errorf("reading namespace %s: %w", env.Namespace, err)
+		}
+		namespaces = append(namespaces, ns)
+	}
+
+	for i := range namespaces {
+		if err := env.importNamespace(ctx, &namespaces[i], opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importNamespace adopts the labels of ns into the NamespaceLabel opts.name.
+func (env *Env) importNamespace(ctx context.Context, ns *corev1.Namespace, opts importOptions) error {
+	var list danaiov1alpha1.NamespaceLabelList
+	if err := env.Client.List(ctx, &list, client.InNamespace(ns.Name)); err != nil {
+		return fmt.Errorf("listing NamespaceLabels in %s: %w", ns.Name, err)
+	}
+
+	adopted := adoptableLabels(ns.Labels, list.Items, env.Policy, opts.excludeKeys)
+	if len(adopted) == 0 {
+		return nil
+	}
+
+	nl := &danaiov1alpha1.NamespaceLabel{
+		ObjectMeta: metav1.ObjectMeta{Name: opts.name, Namespace: ns.Name},
+	}
+	for i := range list.Items {
+		if list.Items[i].Name == opts.name {
+			nl = &list.Items[i]
+		}
+	}
+	adopt(nl, adopted)
+
+	if !opts.create {
+		return env.printManifest(nl)
+	}
+	if err := env.writeAdopted(ctx, nl, adopted); err != nil {
+		return fmt.Errorf("writing NamespaceLabel %s/%s: %w", nl.Namespace, nl.Name, err)
+	}
+	fmt.Fprintf(env.Out, "namespacelabel/%s adopted %d labels in %s\n", nl.Name, len(adopted), ns.Name)
+	return nil
+}
+
+// adoptableLabels returns the labels in current that may be handed to a
+// NamespaceLabel: the policy accepts them, they match no exclude pattern and
+// no NamespaceLabel in items requests or owns them.
+func adoptableLabels(current map[string]string, items []danaiov1alpha1.NamespaceLabel,
+	policy plan.Policy, exclude []string) map[string]string {
+	taken := plan.ClaimedKeys("", items)
+	for i := range items {
+		for key := range requestedLabels(&items[i]) {
+			taken[key] = items[i].Name
+		}
+	}
+
+	adopted := map[string]string{}
+	for key, value := range current {
+		if _, ok := taken[key]; ok {
+			continue
+		}
+		if policy.Check(key, value) != nil || matchesAny(exclude, key) {
+			continue
+		}
+		adopted[key] = value
+	}
+	return adopted
+}
+
+// adopt adds labels to the spec of nl and records them as applied.
+func adopt(nl *danaiov1alpha1.NamespaceLabel, labels map[string]string) {
+	if nl.Spec.Labels == nil {
+		nl.Spec.Labels = map[string]string{}
+	}
+	if nl.Status.AppliedLabels == nil {
+		nl.Status.AppliedLabels = map[string]string{}
+	}
+	for key, value := range labels {
+		nl.Spec.Labels[key] = value
+		nl.Status.AppliedLabels[key] = value
+	}
+}
+
+// writeAdopted creates or updates nl and then records adopted as applied in
+// its status, retrying if the controller updated the status in between.
+func (env *Env) writeAdopted(ctx context.Context, nl *danaiov1alpha1.NamespaceLabel,
+	adopted map[string]string) error {
+	if nl.ResourceVersion == "" {
+		if err := env.Client.Create(ctx, nl.DeepCopy()); err != nil {
+			return err
+		}
+	} else if err := env.Client.Update(ctx, nl.DeepCopy()); err != nil {
+		return err
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current := &danaiov1alpha1.NamespaceLabel{}
+		if err := env.Client.Get(ctx, client.ObjectKeyFromObject(nl), current); err != nil {
+			return err
+		}
+		if current.Status.AppliedLabels == nil {
+			current.Status.AppliedLabels = map[string]string{}
+		}
+		for key, value := range adopted {
+			current.Status.AppliedLabels[key] = value
+		}
+		return env.Client.Status().Update(ctx, current)
+	})
+}
+
+// printManifest writes nl as a YAML document without server-set metadata or
+// status. kubectl apply ignores the status, so the adopted labels are recorded
+// as applied by the controller on its first reconcile instead.
+func (env *Env) printManifest(nl *danaiov1alpha1.NamespaceLabel) error {
+	manifest := &danaiov1alpha1.NamespaceLabel{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: danaiov1alpha1.GroupVersion.String(),
+			Kind:       "NamespaceLabel",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        nl.Name,
+			Namespace:   nl.Namespace,
+			Labels:      nl.Labels,
+			Annotations: nl.Annotations,
+		},
+		Spec: nl.Spec,
+	}
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Out, "---\n%s", data)
+	return nil
+}
+
+// validatePatterns returns an error for the first malformed glob pattern.
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchesAny reports whether name matches one of the glob patterns.
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
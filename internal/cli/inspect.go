@@ -0,0 +1,466 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+	"github.com/TalDebi/namespacelabel/internal/sources"
+)
+
+func newListCommand(env *Env) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the labels requested for the namespace and their state",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return env.list(cmd.Context())
+		},
+	}
+}
+
+func newDiffCommand(env *Env) *cobra.Command {
+	var (
+		filenames []string
+		snapshot  []string
+		exitCode  bool
+	)
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Show the label changes the operator would make to the namespace",
+		Long: "Show the label changes the operator would make to the namespace.\n\n" +
+			"With -f, compare NamespaceLabel manifests instead of the objects in the cluster\n" +
+			"and report the changes for every namespace they target. Namespaces and the\n" +
+			"existing NamespaceLabels are read from the cluster, or from --snapshot files\n" +
+			"such as the output of 'kubectl get namespaces,namespacelabels -A -o yaml'.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			var changes bool
+			var err error
+			switch {
+			case len(filenames) > 0:
+				changes, err = env.diffManifests(cmd.Context(), filenames, snapshot)
+			case len(snapshot) > 0:
+				return errors.New("--snapshot requires -f")
+			default:
+				changes, err = env.diff(cmd.Context())
+			}
+			if err == nil && changes && exitCode {
+				err = errChanges
+			}
+			return err
+		},
+	}
+	cmd.Flags().StringSliceVarP(&filenames, "filename", "f", nil,
+		"Files or directories of NamespaceLabel manifests to compare.")
+	cmd.Flags().StringSliceVar(&snapshot, "snapshot", nil,
+		"Files or directories holding Namespaces and NamespaceLabels to compare against instead of the cluster.")
+	cmd.Flags().BoolVar(&exitCode, "exit-code", false, "Exit with an error when there are changes.")
+	return cmd
+}
+
+// errChanges is returned by diff --exit-code when there are changes.
+var errChanges = errors.New("changes found")
+
+func newExplainCommand(env *Env) *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain KEY",
+		Short: "Explain why a label key was or would be rejected",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return env.explain(cmd.Context(), args[0])
+		},
+	}
+}
+
+func newWhoOwnsCommand(env *Env) *cobra.Command {
+	return &cobra.Command{
+		Use:   "who-owns KEY",
+		Short: "Show which NamespaceLabel owns a label key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return env.whoOwns(cmd.Context(), args[0])
+		},
+	}
+}
+
+// printPlan writes p as a diff: + for adds, ~ for updates, - for removals
+// and ! for rejected keys, or a single ! line when the plan is withheld for
+// breaking the quota. Every line starts with indent.
+func printPlan(out io.Writer, indent string, p plan.Plan) {
+	if p.QuotaExceeded != "" {
+		fmt.Fprintf(out, "%s! QuotaExceeded: the NamespaceLabel %s; no labels would be applied\n",
+			indent, p.QuotaExceeded)
+		return
+	}
+	for _, c := range p.Add {
+		fmt.Fprintf(out, "%s+ %s=%s\n", indent, c.Key, c.New)
+	}
+	for _, c := range p.Update {
+		fmt.Fprintf(out, "%s~ %s=%s -> %s\n", indent, c.Key, c.Old, c.New)
+	}
+	for _, c := range p.Remove {
+		fmt.Fprintf(out, "%s- %s=%s\n", indent, c.Key, c.Old)
+	}
+	for _, r := range p.Rejected {
+		fmt.Fprintf(out, "%s! %s: %s: %s\n", indent, r.Key, r.Reason, r.Message)
+	}
+}
+
+// labelState describes what happened to key requested by nl.
+func labelState(nl *danaiov1alpha1.NamespaceLabel, key string) string {
+	for _, rejected := range nl.Status.RejectedLabels {
+		if rejected.Key == key {
+			return "Rejected (" + rejected.Reason + ")"
+		}
+	}
+	if applied, ok := nl.Status.AppliedLabels[key]; ok && applied == requestedLabels(nl)[key] {
+		return "Applied"
+	}
+	if nl.Spec.Mode == danaiov1alpha1.ModePlan {
+		return "Planned"
+	}
+	return "Pending"
+}
+
+func (env *Env) list(ctx context.Context) error {
+	items, err := env.namespaceLabels(ctx)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		fmt.Fprintf(env.Out, "No NamespaceLabels found in %s.\n", env.Namespace)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(env.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE\tNAMESPACELABEL\tSTATE")
+	for i := range items {
+		nl := &items[i]
+		requested := requestedLabels(nl)
+		for _, key := range plan.SortedKeys(requested) {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", key, requested[key], nl.Name, labelState(nl, key))
+		}
+	}
+	return w.Flush()
+}
+
+// diff prints the changes for the NamespaceLabels in the managed namespace
+// and reports whether there are any.
+func (env *Env) diff(ctx context.Context) (bool, error) {
+	items, err := env.namespaceLabels(ctx)
+	if err != nil {
+		return false, err
+	}
+	var ns corev1.Namespace
+	if err := env.Client.Get(ctx, client.ObjectKey{Name: env.Namespace}, &ns); err != nil {
+		return false, fmt.Errorf("reading namespace %s: %w", env.Namespace, err)
+	}
+
+	changes := false
+	for i := range items {
+		resolved, err := env.resolve(ctx, env.Client, &items[i])
+		if err != nil {
+			return false, err
+		}
+		p := plan.ForNamespaceLabel(&items[i], &ns, items, resolved, env.Policy)
+		if p.Empty() && len(p.Rejected) == 0 && p.QuotaExceeded == "" {
+			continue
+		}
+		changes = true
+		fmt.Fprintf(env.Out, "namespacelabel/%s:\n", items[i].Name)
+		printPlan(env.Out, "", p)
+	}
+	if !changes {
+		fmt.Fprintln(env.Out, "No changes.")
+	}
+	return changes, nil
+}
+
+// diffManifests prints, per namespace, the changes the NamespaceLabel
+// manifests under filenames would cause and reports whether there are any.
+// Namespaces and the NamespaceLabels already in them are read from snapshot
+// when given and from the cluster otherwise; a manifest replaces the spec of
+// the existing object of the same name but keeps its status, so ownership is
+// judged as the controller would. Namespaces excluded by env.Namespaces are
+// listed as skipped, since the controller leaves them alone.
+func (env *Env) diffManifests(ctx context.Context, filenames, snapshot []string) (bool, error) {
+	manifests, err := loadObjects(filenames)
+	if err != nil {
+		return false, err
+	}
+	if len(manifests.namespaceLabels) == 0 {
+		return false, fmt.Errorf("no NamespaceLabel manifests found in %s", strings.Join(filenames, ", "))
+	}
+
+	var (
+		state  *objects
+		reader client.Reader
+	)
+	if len(snapshot) > 0 {
+		if state, err = loadObjects(snapshot); err != nil {
+			return false, err
+		}
+	} else {
+		if err := env.ensureClient(); err != nil {
+			return false, err
+		}
+		reader = env.Client
+	}
+
+	byNamespace := map[string][]danaiov1alpha1.NamespaceLabel{}
+	for _, nl := range manifests.namespaceLabels {
+		if nl.Namespace == "" {
+			nl.Namespace = env.Namespace
+		}
+		if nl.Namespace == "" {
+			return false, fmt.Errorf("NamespaceLabel %s has no namespace; set metadata.namespace or pass -n", nl.Name)
+		}
+		for _, other := range byNamespace[nl.Namespace] {
+			if other.Name == nl.Name {
+				return false, fmt.Errorf("NamespaceLabel %s/%s is defined more than once", nl.Namespace, nl.Name)
+			}
+		}
+		byNamespace[nl.Namespace] = append(byNamespace[nl.Namespace], nl)
+	}
+
+	changes := false
+	for _, namespace := range plan.SortedKeys(byNamespace) {
+		ns, existing, err := env.namespaceState(ctx, state, namespace)
+		if err != nil {
+			return false, err
+		}
+		siblings, planned := withManifests(existing, byNamespace[namespace])
+
+		header := fmt.Sprintf("namespace/%s:\n", namespace)
+		if ns == nil {
+			header = fmt.Sprintf("namespace/%s (not found):\n", namespace)
+			ns = &corev1.Namespace{}
+		}
+		if pattern, excluded := env.Namespaces.ExcludedBy(namespace); excluded {
+			fmt.Fprintf(env.Out, "namespace/%s skipped: matches the excluded pattern %q\n", namespace, pattern)
+			continue
+		}
+		if !env.Namespaces.Selects(ns.Labels) {
+			fmt.Fprintf(env.Out, "namespace/%s skipped: does not match the namespace selector %q\n",
+				namespace, env.Namespaces.Selector)
+			continue
+		}
+		for _, i := range planned {
+			nl := &siblings[i]
+			resolved, err := env.resolve(ctx, reader, nl)
+			if err != nil {
+				return false, err
+			}
+			p := plan.ForNamespaceLabel(nl, ns, siblings, resolved, env.Policy)
+			if p.Empty() && len(p.Rejected) == 0 && p.QuotaExceeded == "" {
+				continue
+			}
+			changes = true
+			fmt.Fprint(env.Out, header)
+			header = ""
+			if nl.Spec.Mode == danaiov1alpha1.ModePlan {
+				fmt.Fprintf(env.Out, "  namespacelabel/%s (Plan mode, not applied):\n", nl.Name)
+			} else {
+				fmt.Fprintf(env.Out, "  namespacelabel/%s:\n", nl.Name)
+			}
+			printPlan(env.Out, "  ", p)
+		}
+	}
+	if !changes {
+		fmt.Fprintln(env.Out, "No changes.")
+	}
+	return changes, nil
+}
+
+// namespaceState returns namespace and the NamespaceLabels in it, from state
+// when it is set and from the cluster otherwise. The namespace is nil when it
+// does not exist.
+func (env *Env) namespaceState(ctx context.Context, state *objects,
+	namespace string) (*corev1.Namespace, []danaiov1alpha1.NamespaceLabel, error) {
+	if state != nil {
+		return state.namespaces[namespace], state.inNamespace(namespace), nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := env.Client.Get(ctx, client.ObjectKey{Name: namespace}, ns); apierrors.IsNotFound(err) {
+		ns = nil
+	} else if err != nil {
+		return nil, nil, fmt.Errorf("reading namespace %s: %w", namespace, err)
+	}
+	var list danaiov1alpha1.NamespaceLabelList
+	if err := env.Client.List(ctx, &list, client.InNamespace(namespace)); err != nil {
+		return nil, nil, fmt.Errorf("listing NamespaceLabels in %s: %w", namespace, err)
+	}
+	return ns, list.Items, nil
+}
+
+// resolve reads the spec.labelsFrom values of nl through reader. Without a
+// reader every such key is reported unresolved, which keeps the value the
+// NamespaceLabel already owns.
+func (env *Env) resolve(ctx context.Context, reader client.Reader,
+	nl *danaiov1alpha1.NamespaceLabel) (plan.Resolved, error) {
+	if reader == nil {
+		var resolved plan.Resolved
+		for _, from := range nl.Spec.LabelsFrom {
+			resolved.Rejected = append(resolved.Rejected, danaiov1alpha1.RejectedLabel{
+				Key:     from.Key,
+				Reason:  danaiov1alpha1.RejectedReasonUnresolved,
+				Message: "sources are not read from a snapshot",
+			})
+		}
+		return resolved, nil
+	}
+	return sources.Resolve(ctx, reader, nl, env.Policy)
+}
+
+// withManifests returns existing with the spec of every object replaced by
+// the manifest of the same name, followed by the manifests that do not exist
+// yet, and the indexes of the manifests in the result.
+func withManifests(existing, manifests []danaiov1alpha1.NamespaceLabel) ([]danaiov1alpha1.NamespaceLabel, []int) {
+	siblings := make([]danaiov1alpha1.NamespaceLabel, 0, len(existing)+len(manifests))
+	index := map[string]int{}
+	for _, nl := range existing {
+		index[nl.Name] = len(siblings)
+		siblings = append(siblings, *nl.DeepCopy())
+	}
+
+	planned := make([]int, 0, len(manifests))
+	for _, nl := range manifests {
+		i, ok := index[nl.Name]
+		if ok {
+			siblings[i].Spec = *nl.Spec.DeepCopy()
+		} else {
+			i = len(siblings)
+			fresh := nl.DeepCopy()
+			fresh.Status = danaiov1alpha1.NamespaceLabelStatus{}
+			siblings = append(siblings, *fresh)
+		}
+		planned = append(planned, i)
+	}
+	return siblings, planned
+}
+
+func (env *Env) explain(ctx context.Context, key string) error {
+	items, err := env.namespaceLabels(ctx)
+	if err != nil {
+		return err
+	}
+
+	requested := false
+	for i := range items {
+		if _, ok := requestedValue(&items[i], key); ok {
+			requested = true
+			fmt.Fprintln(env.Out, env.explainRequested(&items[i], key))
+		}
+	}
+	if requested {
+		return nil
+	}
+
+	if rejected := env.Policy.Check(key, ""); rejected != nil {
+		fmt.Fprintf(env.Out, "%s would be rejected: %s: %s\n", key, rejected.Reason, rejected.Message)
+		if strings.HasPrefix(key, plan.PodSecurityPrefix) {
+			fmt.Fprintln(env.Out, "Set spec.podSecurity to manage Pod Security Admission labels.")
+		}
+		return nil
+	}
+	if owner, claimed := plan.ClaimedKeys("", items)[key]; claimed {
+		fmt.Fprintf(env.Out, "%s would be rejected: %s: key is owned by NamespaceLabel %q\n",
+			key, danaiov1alpha1.RejectedReasonConflict, owner)
+		return nil
+	}
+	fmt.Fprintf(env.Out, "%s is not requested and would be accepted.\n", key)
+	return nil
+}
+
+// explainRequested describes the fate of key as requested by nl.
+func (env *Env) explainRequested(nl *danaiov1alpha1.NamespaceLabel, key string) string {
+	for _, rejected := range nl.Status.RejectedLabels {
+		if rejected.Key == key {
+			return fmt.Sprintf("%s requested by namespacelabel/%s was rejected: %s: %s",
+				key, nl.Name, rejected.Reason, rejected.Message)
+		}
+	}
+	check := env.Policy.Check
+	if _, ok := plan.PodSecurityLabels(nl.Spec.PodSecurity)[key]; ok {
+		check = env.Policy.CheckPodSecurity
+	}
+	value, _ := requestedValue(nl, key)
+	if rejected := check(key, value); rejected != nil {
+		return fmt.Sprintf("%s requested by namespacelabel/%s will be rejected: %s: %s",
+			key, nl.Name, rejected.Reason, rejected.Message)
+	}
+	return fmt.Sprintf("%s requested by namespacelabel/%s is %s.", key, nl.Name, labelState(nl, key))
+}
+
+// requestedLabels returns the labels nl requests through spec.labels,
+// spec.labelsFrom and spec.podSecurity, in increasing precedence. The value
+// of a spec.labelsFrom key is the one the controller last applied, or empty
+// if it has not applied one.
+func requestedLabels(nl *danaiov1alpha1.NamespaceLabel) map[string]string {
+	labels := maps.Clone(nl.Spec.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for _, from := range nl.Spec.LabelsFrom {
+		labels[from.Key] = nl.Status.AppliedLabels[from.Key]
+	}
+	maps.Copy(labels, plan.PodSecurityLabels(nl.Spec.PodSecurity))
+	return labels
+}
+
+// requestedValue returns the value nl requests for key.
+func requestedValue(nl *danaiov1alpha1.NamespaceLabel, key string) (string, bool) {
+	value, ok := requestedLabels(nl)[key]
+	return value, ok
+}
+
+func (env *Env) whoOwns(ctx context.Context, key string) error {
+	items, err := env.namespaceLabels(ctx)
+	if err != nil {
+		return err
+	}
+	if owner, claimed := plan.ClaimedKeys("", items)[key]; claimed {
+		fmt.Fprintf(env.Out, "%s is owned by namespacelabel/%s\n", key, owner)
+		return nil
+	}
+
+	var ns corev1.Namespace
+	if err := env.Client.Get(ctx, client.ObjectKey{Name: env.Namespace}, &ns); err != nil {
+		return fmt.Errorf("reading namespace %s: %w", env.Namespace, err)
+	}
+	if value, ok := ns.Labels[key]; ok {
+		fmt.Fprintf(env.Out, "%s=%s is not managed by any NamespaceLabel\n", key, value)
+		return nil
+	}
+	fmt.Fprintf(env.Out, "%s is not set on namespace %s\n", key, env.Namespace)
+	return nil
+}
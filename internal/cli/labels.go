@@ -0,0 +1,176 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+// defaultObjectName is the NamespaceLabel new keys go to unless --name is set.
+const defaultObjectName = "labels"
+
+func newSetCommand(env *Env) *cobra.Command {
+	name := defaultObjectName
+	cmd := &cobra.Command{
+		Use:   "set KEY=VALUE...",
+		Short: "Set labels on the namespace",
+		Long: "Set labels on the namespace. A key already requested by a NamespaceLabel is\n" +
+			"updated in place; new keys are added to the object named by --name.",
+		Example: "  kubectl nslabel set team=platform tier=gold -n team-a",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			labels, err := parseAssignments(args)
+			if err != nil {
+				return err
+			}
+			return env.set(cmd.Context(), name, labels)
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", name, "The NamespaceLabel that receives keys no object requests yet.")
+	return cmd
+}
+
+func newUnsetCommand(env *Env) *cobra.Command {
+	return &cobra.Command{
+		Use:     "unset KEY...",
+		Short:   "Remove labels from the namespace",
+		Long:    "Remove keys from the NamespaceLabels requesting them. Objects left empty are deleted.",
+		Example: "  kubectl nslabel unset tier -n team-a",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return env.unset(cmd.Context(), args)
+		},
+	}
+}
+
+// parseAssignments parses KEY=VALUE arguments.
+func parseAssignments(args []string) (map[string]string, error) {
+	labels := make(map[string]string, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%q is not of the form KEY=VALUE", arg)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// set writes labels to the NamespaceLabels requesting them, or to the object
+// called name for keys nobody requests yet.
+func (env *Env) set(ctx context.Context, name string, labels map[string]string) error {
+	for key, value := range labels {
+		if rejected := env.Policy.Check(key, value); rejected != nil {
+			return fmt.Errorf("label %s would be rejected: %s: %s", key, rejected.Reason, rejected.Message)
+		}
+	}
+
+	items, err := env.namespaceLabels(ctx)
+	if err != nil {
+		return err
+	}
+
+	changed := map[string]*danaiov1alpha1.NamespaceLabel{}
+	var created *danaiov1alpha1.NamespaceLabel
+	target := func(key string) *danaiov1alpha1.NamespaceLabel {
+		for i := range items {
+			if _, ok := items[i].Spec.Labels[key]; ok {
+				return &items[i]
+			}
+		}
+		for i := range items {
+			if items[i].Name == name {
+				return &items[i]
+			}
+		}
+		if created == nil {
+			created = &danaiov1alpha1.NamespaceLabel{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: env.Namespace},
+			}
+		}
+		return created
+	}
+	for _, key := range plan.SortedKeys(labels) {
+		nl := target(key)
+		if nl.Spec.Labels == nil {
+			nl.Spec.Labels = map[string]string{}
+		}
+		nl.Spec.Labels[key] = labels[key]
+		changed[nl.Name] = nl
+	}
+
+	for _, objName := range plan.SortedKeys(changed) {
+		nl := changed[objName]
+		if nl.ResourceVersion == "" {
+			err = env.Client.Create(ctx, nl)
+		} else {
+			err = env.Client.Update(ctx, nl)
+		}
+		if err != nil {
+			return fmt.Errorf("writing NamespaceLabel %s: %w", nl.Name, err)
+		}
+		fmt.Fprintf(env.Out, "namespacelabel/%s updated\n", nl.Name)
+	}
+	return nil
+}
+
+// unset removes keys from every NamespaceLabel requesting them.
+func (env *Env) unset(ctx context.Context, keys []string) error {
+	items, err := env.namespaceLabels(ctx)
+	if err != nil {
+		return err
+	}
+
+	changed := map[string]*danaiov1alpha1.NamespaceLabel{}
+	for _, key := range keys {
+		found := false
+		for i := range items {
+			if _, ok := items[i].Spec.Labels[key]; ok {
+				delete(items[i].Spec.Labels, key)
+				changed[items[i].Name] = &items[i]
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("no NamespaceLabel in %s requests %s", env.Namespace, key)
+		}
+	}
+
+	for _, objName := range plan.SortedKeys(changed) {
+		nl := changed[objName]
+		if len(nl.Spec.Labels) == 0 {
+			if err := env.Client.Delete(ctx, nl); err != nil {
+				return fmt.Errorf("deleting NamespaceLabel %s: %w", nl.Name, err)
+			}
+			fmt.Fprintf(env.Out, "namespacelabel/%s deleted\n", nl.Name)
+			continue
+		}
+		if err := env.Client.Update(ctx, nl); err != nil {
+			return fmt.Errorf("writing NamespaceLabel %s: %w", nl.Name, err)
+		}
+		fmt.Fprintf(env.Out, "namespacelabel/%s updated\n", nl.Name)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+)
+
+var decoder = serializer.NewCodecFactory(scheme).UniversalDeserializer()
+
+// objects are the Namespaces and NamespaceLabels read from YAML files.
+type objects struct {
+	namespaces      map[string]*corev1.Namespace
+	namespaceLabels []danaiov1alpha1.NamespaceLabel
+}
+
+// loadObjects reads every .yaml, .yml and .json file under paths. Documents
+// of other kinds are skipped, as are the items of a List.
+func loadObjects(paths []string) (*objects, error) {
+	objs := &objects{namespaces: map[string]*corev1.Namespace{}}
+	for _, root := range paths {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			switch filepath.Ext(path) {
+			case ".yaml", ".yml", ".json":
+			default:
+				if path != root {
+					return nil
+				}
+			}
+			if err := objs.loadFile(path); err != nil {
+				return fmt.Errorf("reading %s: %w", path, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objs, nil
+}
+
+func (objs *objects) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(f))
+	for {
+		doc, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		if err := objs.add(doc); err != nil {
+			return err
+		}
+	}
+}
+
+func (objs *objects) add(doc []byte) error {
+	obj, _, err := decoder.Decode(doc, nil, nil)
+	if runtime.IsNotRegisteredError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	switch o := obj.(type) {
+	case *corev1.Namespace:
+		objs.namespaces[o.Name] = o
+	case *danaiov1alpha1.NamespaceLabel:
+		objs.namespaceLabels = append(objs.namespaceLabels, *o)
+	case *danaiov1alpha1.NamespaceLabelList:
+		objs.namespaceLabels = append(objs.namespaceLabels, o.Items...)
+	case *corev1.NamespaceList:
+		for i := range o.Items {
+			objs.namespaces[o.Items[i].Name] = &o.Items[i]
+		}
+	case *corev1.List:
+		for _, item := range o.Items {
+			if err := objs.add(item.Raw); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// inNamespace returns the NamespaceLabels read for namespace.
+func (objs *objects) inNamespace(namespace string) []danaiov1alpha1.NamespaceLabel {
+	var items []danaiov1alpha1.NamespaceLabel
+	for _, nl := range objs.namespaceLabels {
+		if nl.Namespace == namespace {
+			items = append(items, nl)
+		}
+	}
+	return items
+}
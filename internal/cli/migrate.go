@@ -0,0 +1,174 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+// migrateOptions are the flags of the migrate command.
+type migrateOptions struct {
+	renames       []string
+	allNamespaces bool
+	dryRun        bool
+}
+
+func newMigrateCommand(env *Env) *cobra.Command {
+	var opts migrateOptions
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Rewrite NamespaceLabels from deprecated label keys to their replacements",
+		Long: "Rewrite the spec of every NamespaceLabel requesting a deprecated key to request\n" +
+			"its replacement instead, in spec.labels, spec.labelsFrom and spec.propagate. The\n" +
+			"renames come from --rename, or from policy.keyMigrations of the manager's\n" +
+			"configuration file with --config. With --dry-run the objects are only listed.",
+		Example: "  kubectl nslabel migrate -A --rename team=dana.io/team --dry-run\n" +
+			"  kubectl nslabel migrate -A --config manager-config.yaml",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			renames, err := opts.parseRenames(env.Policy)
+			if err != nil {
+				return err
+			}
+			return env.migrate(cmd.Context(), renames, opts)
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringSliceVar(&opts.renames, "rename", nil, "A rename of the form OLD=NEW. May be repeated.")
+	flags.BoolVarP(&opts.allNamespaces, "all-namespaces", "A", false, "Migrate NamespaceLabels in every namespace.")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "Only list the NamespaceLabels that would be rewritten.")
+	return cmd
+}
+
+// parseRenames returns the renames of policy, read from --config, and those
+// given by --rename.
+func (opts migrateOptions) parseRenames(policy plan.Policy) ([]plan.Rename, error) {
+	renames := slices.Clone(policy.Renames)
+	for _, arg := range opts.renames {
+		from, to, ok := strings.Cut(arg, "=")
+		if !ok || from == "" || to == "" || from == to {
+			return nil, fmt.Errorf("%q is not of the form OLD=NEW", arg)
+		}
+		renames = append(renames, plan.Rename{From: from, To: to})
+	}
+	if len(renames) == 0 {
+		return nil, fmt.Errorf("no renames given; use --rename or --config")
+	}
+	return renames, nil
+}
+
+// migrate rewrites the NamespaceLabels requesting a key renamed by renames.
+func (env *Env) migrate(ctx context.Context, renames []plan.Rename, opts migrateOptions) error {
+	if err := env.ensureClient(); err != nil {
+		return err
+	}
+	var listOpts []client.ListOption
+	if !opts.allNamespaces {
+		listOpts = append(listOpts, client.InNamespace(env.Namespace))
+	}
+	var list danaiov1alpha1.NamespaceLabelList
+	if err := env.Client.List(ctx, &list, listOpts...); err != nil {
+		return fmt.Errorf("listing NamespaceLabels: %w", err)
+	}
+
+	migrated := 0
+	for i := range list.Items {
+		nl := &list.Items[i]
+		moved := migrateSpec(nl.DeepCopy(), renames)
+		if len(moved) == 0 {
+			continue
+		}
+		migrated++
+		if opts.dryRun {
+			fmt.Fprintf(env.Out, "namespacelabel/%s in %s would move %s\n", nl.Name, nl.Namespace, strings.Join(moved, ", "))
+			continue
+		}
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			current := &danaiov1alpha1.NamespaceLabel{}
+			if err := env.Client.Get(ctx, client.ObjectKeyFromObject(nl), current); err != nil {
+				return err
+			}
+			if len(migrateSpec(current, renames)) == 0 {
+				return nil
+			}
+			return env.Client.Update(ctx, current)
+		})
+		if err != nil {
+			return fmt.Errorf("writing NamespaceLabel %s/%s: %w", nl.Namespace, nl.Name, err)
+		}
+		fmt.Fprintf(env.Out, "namespacelabel/%s in %s moved %s\n", nl.Name, nl.Namespace, strings.Join(moved, ", "))
+	}
+	if migrated == 0 {
+		fmt.Fprintln(env.Out, "No NamespaceLabels request a deprecated key.")
+	}
+	return nil
+}
+
+// migrateSpec rewrites the spec of nl to request the replacement of every
+// deprecated key in renames, and returns the moves it made as OLD -> NEW. A
+// value already requested under the new key wins over the deprecated one.
+func migrateSpec(nl *danaiov1alpha1.NamespaceLabel, renames []plan.Rename) []string {
+	var moved []string
+	for _, r := range renames {
+		found := false
+		if value, ok := nl.Spec.Labels[r.From]; ok {
+			if _, exists := nl.Spec.Labels[r.To]; !exists {
+				nl.Spec.Labels[r.To] = value
+			}
+			delete(nl.Spec.Labels, r.From)
+			found = true
+		}
+
+		if i := slices.IndexFunc(nl.Spec.LabelsFrom, func(f danaiov1alpha1.LabelFrom) bool { return f.Key == r.From }); i >= 0 {
+			if slices.ContainsFunc(nl.Spec.LabelsFrom, func(f danaiov1alpha1.LabelFrom) bool { return f.Key == r.To }) {
+				nl.Spec.LabelsFrom = slices.Delete(nl.Spec.LabelsFrom, i, i+1)
+			} else {
+				nl.Spec.LabelsFrom[i].Key = r.To
+			}
+			found = true
+		}
+
+		if p := nl.Spec.Propagate; p != nil && slices.Contains(p.Keys, r.From) {
+			keys := make([]string, 0, len(p.Keys))
+			for _, key := range p.Keys {
+				if key == r.From {
+					key = r.To
+				}
+				if !slices.Contains(keys, key) {
+					keys = append(keys, key)
+				}
+			}
+			p.Keys = keys
+			found = true
+		}
+
+		if found {
+			moved = append(moved, r.From+" -> "+r.To)
+		}
+	}
+	return moved
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+// Report output formats.
+const (
+	formatCSV      = "csv"
+	formatJSON     = "json"
+	formatMarkdown = "markdown"
+)
+
+// reportRow is one label of one namespace.
+type reportRow struct {
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	// NamespaceLabel is the object owning or requesting the key, empty for
+	// labels no NamespaceLabel manages.
+	NamespaceLabel string `json:"namespaceLabel,omitempty"`
+	Status         string `json:"status"`
+	// LastChanged is when the field manager owning the label last wrote the
+	// namespace, nil for labels that are not on the namespace.
+	LastChanged *metav1.Time `json:"lastChanged,omitempty"`
+}
+
+// reportOptions are the flags of the report command.
+type reportOptions struct {
+	output        string
+	allNamespaces bool
+	keys          []string
+}
+
+func newReportCommand(env *Env) *cobra.Command {
+	opts := reportOptions{output: formatCSV}
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Report the labels of namespaces and the NamespaceLabels managing them",
+		Long: "Report every label of the namespace, or of all namespaces with -A, together with\n" +
+			"the NamespaceLabel owning it, its state and when it last changed. Labels that are\n" +
+			"requested but not on the namespace are included with the reason.",
+		Example: "  kubectl nslabel report -A --key cost-center --key owner -o markdown",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			switch opts.output {
+			case formatCSV, formatJSON, formatMarkdown:
+			default:
+				return fmt.Errorf("unknown output format %q, want one of csv, json or markdown", opts.output)
+			}
+			if err := validatePatterns(opts.keys); err != nil {
+				return err
+			}
+			return env.report(cmd.Context(), opts)
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.output, "output", "o", opts.output, "Output format: csv, json or markdown.")
+	flags.BoolVarP(&opts.allNamespaces, "all-namespaces", "A", false, "Report on every namespace.")
+	flags.StringSliceVar(&opts.keys, "key", nil, "Glob patterns of the label keys to report; all keys when unset.")
+	return cmd
+}
+
+func (env *Env) report(ctx context.Context, opts reportOptions) error {
+	if err := env.ensureClient(); err != nil {
+		return err
+	}
+
+	var namespaces []corev1.Namespace
+	var listOpts []client.ListOption
+	if opts.allNamespaces {
+		var list corev1.NamespaceList
+		if err := env.Client.List(ctx, &list); err != nil {
+			return fmt.Errorf("listing namespaces: %w", err)
+		}
+		namespaces = list.Items
+	} else {
+		var ns corev1.Namespace
+		if err := env.Client.Get(ctx, client.ObjectKey{Name: env.Namespace}, &ns); err != nil {
+			return fmt.Errorf("reading namespace %s: %w", env.Namespace, err)
+		}
+		namespaces = append(namespaces, ns)
+		listOpts = append(listOpts, client.InNamespace(env.Namespace))
+	}
+
+	var list danaiov1alpha1.NamespaceLabelList
+	if err := env.Client.List(ctx, &list, listOpts...); err != nil {
+		return fmt.Errorf("listing NamespaceLabels: %w", err)
+	}
+	byNamespace := map[string][]danaiov1alpha1.NamespaceLabel{}
+	for _, nl := range list.Items {
+		byNamespace[nl.Namespace] = append(byNamespace[nl.Namespace], nl)
+	}
+
+	var rows []reportRow
+	for i := range namespaces {
+		ns := &namespaces[i]
+		for _, row := range env.reportRows(ns, byNamespace[ns.Name]) {
+			if len(opts.keys) == 0 || matchesAny(opts.keys, row.Key) {
+				rows = append(rows, row)
+			}
+		}
+	}
+
+	switch opts.output {
+	case formatJSON:
+		return writeJSONReport(env.Out, rows)
+	case formatMarkdown:
+		return writeMarkdownReport(env.Out, rows)
+	default:
+		return writeCSVReport(env.Out, rows)
+	}
+}
+
+// reportRows returns a row for every label on ns and for every key requested
+// by items that is not on it, sorted by key.
+func (env *Env) reportRows(ns *corev1.Namespace, items []danaiov1alpha1.NamespaceLabel) []reportRow {
+	rows := map[string]reportRow{}
+	for key, value := range ns.Labels {
+		row := reportRow{
+			Namespace:   ns.Name,
+			Key:         key,
+			Value:       value,
+			Status:      "Unmanaged",
+			LastChanged: labelChangedAt(ns, key),
+		}
+		if rejected := env.Policy.Check(key, value); rejected != nil {
+			row.Status = "Unmanaged (" + rejected.Reason + ")"
+		}
+		rows[key] = row
+	}
+
+	for i := range items {
+		nl := &items[i]
+		for key, value := range nl.Status.AppliedLabels {
+			if row, ok := rows[key]; ok && row.Value == value {
+				row.NamespaceLabel = nl.Name
+				row.Status = "Applied"
+				rows[key] = row
+			}
+		}
+	}
+	for i := range items {
+		nl := &items[i]
+		for key, value := range requestedLabels(nl) {
+			if row, ok := rows[key]; ok && row.NamespaceLabel != "" {
+				continue
+			}
+			row := rows[key]
+			if row.Key == "" {
+				row = reportRow{Namespace: ns.Name, Key: key, Value: value}
+			}
+			row.NamespaceLabel = nl.Name
+			row.Status = labelState(nl, key)
+			rows[key] = row
+		}
+	}
+
+	out := make([]reportRow, 0, len(rows))
+	for _, key := range plan.SortedKeys(rows) {
+		out = append(out, rows[key])
+	}
+	return out
+}
+
+// labelChangedAt returns when the field manager owning the label key of obj
+// last wrote it, or nil if managedFields do not say.
+func labelChangedAt(obj metav1.Object, key string) *metav1.Time {
+	var latest *metav1.Time
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Time == nil || !slices.Contains(plan.ManagedLabelKeys(entry), key) {
+			continue
+		}
+		if latest == nil || latest.Before(entry.Time) {
+			latest = entry.Time
+		}
+	}
+	return latest
+}
+
+func (r reportRow) lastChanged() string {
+	if r.LastChanged == nil {
+		return ""
+	}
+	return r.LastChanged.UTC().Format(time.RFC3339)
+}
+
+var reportHeader = []string{"namespace", "key", "value", "namespaceLabel", "status", "lastChanged"}
+
+func (r reportRow) fields() []string {
+	return []string{r.Namespace, r.Key, r.Value, r.NamespaceLabel, r.Status, r.lastChanged()}
+}
+
+func writeCSVReport(out io.Writer, rows []reportRow) error {
+	w := csv.NewWriter(out)
+	if err := w.Write(reportHeader); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := w.Write(row.fields()); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func writeJSONReport(out io.Writer, rows []reportRow) error {
+	if rows == nil {
+		rows = []reportRow{}
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+func writeMarkdownReport(out io.Writer, rows []reportRow) error {
+	var b strings.Builder
+	b.WriteString("| Namespace | Key | Value | NamespaceLabel | Status | Last changed |\n")
+	b.WriteString("|---|---|---|---|---|---|\n")
+	for _, row := range rows {
+		b.WriteString("| " + strings.Join(row.fields(), " | ") + " |\n")
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
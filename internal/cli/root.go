@@ -0,0 +1,178 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cli implements kubectl-nslabel, a kubectl plugin that manages
+// namespace labels by editing NamespaceLabel objects.
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/config"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(danaiov1alpha1.AddToScheme(scheme))
+}
+
+// Env is what every subcommand runs against.
+type Env struct {
+	// Client talks to the cluster. It is built from the kubeconfig flags
+	// when left nil.
+	Client client.Client
+	// Namespace is the namespace whose labels are managed.
+	Namespace string
+	// Policy is used to explain rejections before the controller sees them.
+	// The --config flag applies the policy of a ManagerConfig file on top.
+	Policy plan.Policy
+	// Namespaces selects the namespaces the manager handles; diff -f skips
+	// the others. The --config flag applies the namespaces section on top.
+	Namespaces plan.NamespaceFilter
+	// Out receives the command output.
+	Out io.Writer
+
+	// connect sets Client and Namespace from the kubeconfig flags. It runs
+	// on first use so commands working offline never need a cluster.
+	connect func() error
+}
+
+// NewRootCommand returns the kubectl-nslabel command writing to out.
+func NewRootCommand(out io.Writer) *cobra.Command {
+	return newRootCommand(&Env{
+		Policy:     plan.DefaultPolicy(),
+		Namespaces: plan.NamespaceFilter{Exclude: plan.DefaultExcludedNamespaces},
+		Out:        out,
+	})
+}
+
+func newRootCommand(env *Env) *cobra.Command {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{}
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "kubectl-nslabel",
+		Short: "Manage namespace labels through NamespaceLabel objects",
+		Long: "kubectl-nslabel sets and inspects the labels of a namespace by editing the\n" +
+			"NamespaceLabel objects inside it, so the operator applies and tracks them.",
+		Annotations:  map[string]string{cobra.CommandDisplayNameAnnotation: "kubectl nslabel"},
+		SilenceUsage: true,
+		PersistentPreRunE: func(*cobra.Command, []string) error {
+			if overrides.Context.Namespace != "" {
+				env.Namespace = overrides.Context.Namespace
+			}
+			return env.loadConfig(configPath)
+		},
+	}
+	env.connect = func() error {
+		return env.connectWith(loadingRules, overrides)
+	}
+	cmd.SetOut(env.Out)
+
+	flags := cmd.PersistentFlags()
+	flags.StringVar(&loadingRules.ExplicitPath, "kubeconfig", "", "Path to the kubeconfig file to use.")
+	flags.StringVar(&overrides.CurrentContext, "context", "", "The kubeconfig context to use.")
+	flags.StringVarP(&overrides.Context.Namespace, "namespace", "n", "", "The namespace to manage.")
+	flags.StringVar(&configPath, "config", "", "A ManagerConfig file whose policy to use instead of the defaults.")
+
+	cmd.AddCommand(
+		newSetCommand(env),
+		newUnsetCommand(env),
+		newListCommand(env),
+		newDiffCommand(env),
+		newExplainCommand(env),
+		newWhoOwnsCommand(env),
+		newImportCommand(env),
+		newReportCommand(env),
+		newMigrateCommand(env),
+	)
+	return cmd
+}
+
+// loadConfig applies the policy and namespace filter of the ManagerConfig
+// file at path, if any, the same way the manager does.
+func (env *Env) loadConfig(path string) error {
+	if path == "" {
+		return nil
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	settings := config.Settings{Policy: env.Policy, ExcludeNamespaces: env.Namespaces.Exclude}
+	config.Apply(cfg, &settings)
+	env.Policy = settings.Policy
+	env.Namespaces.Exclude = settings.ExcludeNamespaces
+	if settings.NamespaceSelector != "" {
+		// config.Load has already validated the selector.
+		if env.Namespaces.Selector, err = labels.Parse(settings.NamespaceSelector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureClient connects to the cluster unless a client is already set.
+func (env *Env) ensureClient() error {
+	if env.Client != nil || env.connect == nil {
+		return nil
+	}
+	return env.connect()
+}
+
+// connectWith builds the client and resolves the namespace from the kubeconfig.
+func (env *Env) connectWith(loadingRules *clientcmd.ClientConfigLoadingRules,
+	overrides *clientcmd.ConfigOverrides) error {
+	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+	restConfig, err := config.ClientConfig()
+	if err != nil {
+		return fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	if env.Namespace == "" {
+		if env.Namespace, _, err = config.Namespace(); err != nil {
+			return fmt.Errorf("resolving namespace: %w", err)
+		}
+	}
+	env.Client, err = client.New(restConfig, client.Options{Scheme: scheme})
+	return err
+}
+
+// namespaceLabels returns the NamespaceLabel objects in the managed namespace.
+func (env *Env) namespaceLabels(ctx context.Context) ([]danaiov1alpha1.NamespaceLabel, error) {
+	if err := env.ensureClient(); err != nil {
+		return nil, err
+	}
+	var list danaiov1alpha1.NamespaceLabelList
+	if err := env.Client.List(ctx, &list, client.InNamespace(env.Namespace)); err != nil {
+		return nil, fmt.Errorf("listing NamespaceLabels in %s: %w", env.Namespace, err)
+	}
+	return list.Items, nil
+}
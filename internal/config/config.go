@@ -0,0 +1,335 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package config loads the manager configuration file, applies it on top of
+// the command-line flags and reloads it when it changes.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	configv1alpha1 "github.com/TalDebi/namespacelabel/api/config/v1alpha1"
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+var (
+	scheme = runtime.NewScheme()
+	// decoder rejects unknown and duplicate fields so typos are reported
+	// instead of silently ignored.
+	decoder = serializer.NewCodecFactory(scheme, serializer.EnableStrict).UniversalDeserializer()
+)
+
+func init() {
+	utilruntime.Must(configv1alpha1.AddToScheme(scheme))
+}
+
+// Settings are the manager options a ManagerConfig can set.
+type Settings struct {
+	Policy            plan.Policy
+	NamespaceSelector string
+	ExcludeNamespaces []string
+	WatchNamespaces   []string
+	Limits            Limits
+	AuditLogPath      string
+	DefaultLabels     map[string]string
+	DefaultsName      string
+}
+
+// Load reads and validates the configuration file at path.
+func Load(path string) (*configv1alpha1.ManagerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Decode parses and validates a configuration file.
+func Decode(data []byte) (*configv1alpha1.ManagerConfig, error) {
+	obj, gvk, err := decoder.Decode(data, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	cfg, ok := obj.(*configv1alpha1.ManagerConfig)
+	if !ok {
+		return nil, fmt.Errorf("expected kind ManagerConfig in %s, got %s", configv1alpha1.GroupVersion, gvk)
+	}
+	if err := Validate(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate returns every invalid field of cfg as a single error.
+func Validate(cfg *configv1alpha1.ManagerConfig) error {
+	var errs field.ErrorList
+
+	prefixes := field.NewPath("policy", "protectedPrefixes")
+	for i, prefix := range cfg.Policy.ProtectedPrefixes {
+		for _, msg := range validation.IsDNS1123Subdomain(prefix) {
+			errs = append(errs, field.Invalid(prefixes.Index(i), prefix, msg))
+		}
+	}
+
+	switch level := danaiov1alpha1.PodSecurityLevel(cfg.Policy.MaxPodSecurityLevel); level {
+	case "", danaiov1alpha1.PodSecurityPrivileged, danaiov1alpha1.PodSecurityBaseline,
+		danaiov1alpha1.PodSecurityRestricted:
+	default:
+		errs = append(errs, field.NotSupported(field.NewPath("policy", "maxPodSecurityLevel"), level,
+			[]danaiov1alpha1.PodSecurityLevel{danaiov1alpha1.PodSecurityPrivileged,
+				danaiov1alpha1.PodSecurityBaseline, danaiov1alpha1.PodSecurityRestricted}))
+	}
+	migrations := field.NewPath("policy", "keyMigrations")
+	from := map[string]bool{}
+	for _, m := range cfg.Policy.KeyMigrations {
+		from[m.From] = true
+	}
+	seen := map[string]bool{}
+	for i, m := range cfg.Policy.KeyMigrations {
+		for _, msg := range validation.IsQualifiedName(m.From) {
+			errs = append(errs, field.Invalid(migrations.Index(i).Child("from"), m.From, msg))
+		}
+		for _, msg := range validation.IsQualifiedName(m.To) {
+			errs = append(errs, field.Invalid(migrations.Index(i).Child("to"), m.To, msg))
+		}
+		switch {
+		case seen[m.From]:
+			errs = append(errs, field.Duplicate(migrations.Index(i).Child("from"), m.From))
+		case from[m.To]:
+			errs = append(errs, field.Invalid(migrations.Index(i).Child("to"), m.To,
+				"must not be migrated itself"))
+		}
+		seen[m.From] = true
+	}
+	for i, pattern := range cfg.Policy.AllowedSecrets {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, field.Invalid(field.NewPath("policy", "allowedSecrets").Index(i), pattern, err.Error()))
+		}
+	}
+	for i, pattern := range cfg.Policy.AllowedNamespaceRefs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, field.Invalid(field.NewPath("policy", "allowedNamespaceRefs").Index(i),
+				pattern, err.Error()))
+		}
+	}
+	for i, pattern := range cfg.Policy.OverrideFieldManagers {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, field.Invalid(field.NewPath("policy", "overrideFieldManagers").Index(i),
+				pattern, err.Error()))
+		}
+	}
+	quota := field.NewPath("policy", "labelQuota")
+	q := cfg.Policy.LabelQuota
+	nonNegativeInt := func(name string, v *int32) {
+		if v != nil && *v < 0 {
+			errs = append(errs, field.Invalid(quota.Child(name), *v, "must not be negative"))
+		}
+	}
+	nonNegativeInt("maxLabelsPerNamespace", q.MaxLabelsPerNamespace)
+	nonNegativeInt("maxLabelsPerObject", q.MaxLabelsPerObject)
+	nonNegativeInt("maxLabelBytesPerNamespace", q.MaxLabelBytesPerNamespace)
+
+	namespaces := field.NewPath("namespaces")
+	if cfg.Namespaces.Selector != "" {
+		if _, err := labels.Parse(cfg.Namespaces.Selector); err != nil {
+			errs = append(errs, field.Invalid(namespaces.Child("selector"), cfg.Namespaces.Selector, err.Error()))
+		}
+	}
+	for i, pattern := range cfg.Namespaces.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, field.Invalid(namespaces.Child("exclude").Index(i), pattern, err.Error()))
+		}
+	}
+	for i, name := range cfg.Namespaces.Watch {
+		for _, msg := range validation.IsDNS1123Label(name) {
+			errs = append(errs, field.Invalid(namespaces.Child("watch").Index(i), name, msg))
+		}
+	}
+
+	limits := field.NewPath("limits")
+	l := cfg.Limits
+	positiveInt := func(name string, v *int32) {
+		if v != nil && *v <= 0 {
+			errs = append(errs, field.Invalid(limits.Child(name), *v, "must be greater than zero"))
+		}
+	}
+	positiveDuration := func(name string, v *metav1.Duration) {
+		if v != nil && v.Duration <= 0 {
+			errs = append(errs, field.Invalid(limits.Child(name), v.Duration.String(), "must be greater than zero"))
+		}
+	}
+	positiveInt("maxConcurrentReconciles", l.MaxConcurrentReconciles)
+	positiveInt("requeueBurst", l.RequeueBurst)
+	positiveInt("namespaceWriteBurst", l.NamespaceWriteBurst)
+	positiveDuration("requeueBaseDelay", l.RequeueBaseDelay)
+	positiveDuration("requeueMaxDelay", l.RequeueMaxDelay)
+	if l.RequeueBaseDelay != nil && l.RequeueMaxDelay != nil && l.RequeueBaseDelay.Duration > l.RequeueMaxDelay.Duration {
+		errs = append(errs, field.Invalid(limits.Child("requeueBaseDelay"), l.RequeueBaseDelay.Duration.String(),
+			"must not be longer than requeueMaxDelay"))
+	}
+	if l.RequeueQPS != nil && *l.RequeueQPS <= 0 {
+		errs = append(errs, field.Invalid(limits.Child("requeueQPS"), *l.RequeueQPS, "must be greater than zero"))
+	}
+	if l.NamespaceWritesPerSecond != nil && *l.NamespaceWritesPerSecond < 0 {
+		errs = append(errs, field.Invalid(limits.Child("namespaceWritesPerSecond"), *l.NamespaceWritesPerSecond,
+			"must not be negative"))
+	}
+
+	defaults := field.NewPath("defaults")
+	for key, value := range cfg.Defaults.Labels {
+		for _, msg := range validation.IsQualifiedName(key) {
+			errs = append(errs, field.Invalid(defaults.Child("labels").Key(key), key, msg))
+		}
+		// The user name is made a valid label value when it is substituted.
+		for _, msg := range validation.IsValidLabelValue(strings.ReplaceAll(value, "${user}", "user")) {
+			errs = append(errs, field.Invalid(defaults.Child("labels").Key(key), value, msg))
+		}
+	}
+	if name := cfg.Defaults.NamespaceLabel; name != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			errs = append(errs, field.Invalid(defaults.Child("namespaceLabel"), name, msg))
+		}
+	}
+
+	return errs.ToAggregate()
+}
+
+// Apply overwrites s with every field set in cfg.
+func Apply(cfg *configv1alpha1.ManagerConfig, s *Settings) {
+	if cfg.Policy.ProtectedPrefixes != nil {
+		s.Policy.ProtectedPrefixes = cfg.Policy.ProtectedPrefixes
+	}
+	if cfg.Policy.MaxPodSecurityLevel != "" {
+		s.Policy.MaxPodSecurityLevel = danaiov1alpha1.PodSecurityLevel(cfg.Policy.MaxPodSecurityLevel)
+	}
+	if cfg.Policy.AllowedSecrets != nil {
+		s.Policy.AllowedSecrets = cfg.Policy.AllowedSecrets
+	}
+	if cfg.Policy.AllowedNamespaceRefs != nil {
+		s.Policy.AllowedNamespaceRefs = cfg.Policy.AllowedNamespaceRefs
+	}
+	if cfg.Policy.KeyMigrations != nil {
+		s.Policy.Renames = Renames(cfg.Policy.KeyMigrations)
+	}
+	if cfg.Policy.OverrideFieldManagers != nil {
+		s.Policy.OverrideFieldManagers = cfg.Policy.OverrideFieldManagers
+	}
+	q := cfg.Policy.LabelQuota
+	setInt(&s.Policy.Quota.MaxLabelsPerNamespace, q.MaxLabelsPerNamespace)
+	setInt(&s.Policy.Quota.MaxLabelsPerObject, q.MaxLabelsPerObject)
+	setInt(&s.Policy.Quota.MaxLabelBytesPerNamespace, q.MaxLabelBytesPerNamespace)
+
+	if cfg.Namespaces.Selector != "" {
+		s.NamespaceSelector = cfg.Namespaces.Selector
+	}
+	if cfg.Namespaces.Exclude != nil {
+		s.ExcludeNamespaces = cfg.Namespaces.Exclude
+	}
+	if cfg.Namespaces.Watch != nil {
+		s.WatchNamespaces = cfg.Namespaces.Watch
+	}
+
+	l := cfg.Limits
+	setInt(&s.Limits.MaxConcurrentReconciles, l.MaxConcurrentReconciles)
+	setDuration(&s.Limits.BaseDelay, l.RequeueBaseDelay)
+	setDuration(&s.Limits.MaxDelay, l.RequeueMaxDelay)
+	setFloat(&s.Limits.QPS, l.RequeueQPS)
+	setInt(&s.Limits.Burst, l.RequeueBurst)
+	setFloat(&s.Limits.NamespaceWritesPerSecond, l.NamespaceWritesPerSecond)
+	setInt(&s.Limits.NamespaceWriteBurst, l.NamespaceWriteBurst)
+
+	if cfg.Audit.LogPath != "" {
+		s.AuditLogPath = cfg.Audit.LogPath
+	}
+
+	if cfg.Defaults.Labels != nil {
+		s.DefaultLabels = cfg.Defaults.Labels
+	}
+	if cfg.Defaults.NamespaceLabel != "" {
+		s.DefaultsName = cfg.Defaults.NamespaceLabel
+	}
+}
+
+// RestartRequired returns the fields that differ between old and cfg and
+// only take effect when the manager restarts.
+func RestartRequired(old, cfg *configv1alpha1.ManagerConfig) []string {
+	var changed []string
+	if !equality.Semantic.DeepEqual(old.Namespaces, cfg.Namespaces) {
+		changed = append(changed, "namespaces")
+	}
+	oldLimits, newLimits := old.Limits, cfg.Limits
+	oldLimits.NamespaceWritesPerSecond, oldLimits.NamespaceWriteBurst = nil, nil
+	newLimits.NamespaceWritesPerSecond, newLimits.NamespaceWriteBurst = nil, nil
+	if !equality.Semantic.DeepEqual(oldLimits, newLimits) {
+		changed = append(changed, "limits")
+	}
+	if old.Audit != cfg.Audit {
+		changed = append(changed, "audit")
+	}
+	if old.Defaults.NamespaceLabel != cfg.Defaults.NamespaceLabel {
+		changed = append(changed, "defaults.namespaceLabel")
+	}
+	return changed
+}
+
+// Renames converts key migrations to the renames of a plan.Policy.
+func Renames(migrations []configv1alpha1.KeyMigration) []plan.Rename {
+	renames := make([]plan.Rename, 0, len(migrations))
+	for _, m := range migrations {
+		renames = append(renames, plan.Rename{
+			From:      m.From,
+			To:        m.To,
+			DualWrite: m.DualWrite == nil || *m.DualWrite,
+		})
+	}
+	return renames
+}
+
+func setInt(dst *int, v *int32) {
+	if v != nil {
+		*dst = int(*v)
+	}
+}
+
+func setFloat(dst *float64, v *float64) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func setDuration(dst *time.Duration, v *metav1.Duration) {
+	if v != nil {
+		*dst = v.Duration
+	}
+}
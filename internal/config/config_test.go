@@ -0,0 +1,203 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1alpha1 "github.com/TalDebi/namespacelabel/api/config/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+const sample = `apiVersion: config.dana.io.namespacelabel.com/v1alpha1
+kind: ManagerConfig
+policy:
+  protectedPrefixes: [kubernetes.io, k8s.io, example.com]
+  maxPodSecurityLevel: restricted
+  allowedSecrets: ["team-*"]
+  allowedNamespaceRefs: [platform]
+  keyMigrations:
+  - from: team
+    to: dana.io/team
+  - from: cost
+    to: dana.io/cost-center
+    dualWrite: false
+  overrideFieldManagers: [kubectl, helm]
+  labelQuota:
+    maxLabelsPerNamespace: 100
+    maxLabelBytesPerNamespace: 16384
+namespaces:
+  selector: tenant=true
+  exclude: ["kube-*"]
+limits:
+  maxConcurrentReconciles: 4
+  requeueBaseDelay: 10ms
+  namespaceWritesPerSecond: 20
+audit:
+  logPath: /var/log/namespacelabel/audit.log
+`
+
+var _ = Describe("ManagerConfig", func() {
+	It("applies the fields it sets on top of the flags", func() {
+		cfg, err := Decode([]byte(sample))
+		Expect(err).NotTo(HaveOccurred())
+
+		settings := Settings{
+			Policy:            plan.DefaultPolicy(),
+			ExcludeNamespaces: plan.DefaultExcludedNamespaces,
+			WatchNamespaces:   []string{"team-a"},
+			Limits:            DefaultLimits(),
+		}
+		Apply(cfg, &settings)
+
+		Expect(settings.Policy.ProtectedPrefixes).To(Equal([]string{"kubernetes.io", "k8s.io", "example.com"}))
+		Expect(settings.Policy.MaxPodSecurityLevel).To(BeEquivalentTo("restricted"))
+		Expect(settings.Policy.AllowedSecrets).To(Equal([]string{"team-*"}))
+		Expect(settings.Policy.AllowedNamespaceRefs).To(Equal([]string{"platform"}))
+		Expect(settings.Policy.OverrideFieldManagers).To(Equal([]string{"kubectl", "helm"}))
+		Expect(settings.Policy.Quota).To(Equal(plan.Quota{MaxLabelsPerNamespace: 100, MaxLabelBytesPerNamespace: 16384}))
+		Expect(settings.Policy.Renames).To(Equal([]plan.Rename{
+			{From: "team", To: "dana.io/team", DualWrite: true},
+			{From: "cost", To: "dana.io/cost-center"},
+		}))
+		Expect(settings.NamespaceSelector).To(Equal("tenant=true"))
+		Expect(settings.ExcludeNamespaces).To(Equal([]string{"kube-*"}))
+		Expect(settings.WatchNamespaces).To(Equal([]string{"team-a"}))
+		Expect(settings.Limits.MaxConcurrentReconciles).To(Equal(4))
+		Expect(settings.Limits.BaseDelay).To(Equal(10 * time.Millisecond))
+		Expect(settings.Limits.MaxDelay).To(Equal(DefaultLimits().MaxDelay))
+		Expect(settings.Limits.NamespaceWritesPerSecond).To(Equal(20.0))
+		Expect(settings.AuditLogPath).To(Equal("/var/log/namespacelabel/audit.log"))
+	})
+
+	It("rejects unknown fields and other kinds", func() {
+		_, err := Decode([]byte("apiVersion: config.dana.io.namespacelabel.com/v1alpha1\n" +
+			"kind: ManagerConfig\npolicy:\n  protectedPrefix: [example.com]\n"))
+		Expect(err).To(MatchError(ContainSubstring(`unknown field "policy.protectedPrefix"`)))
+
+		_, err = Decode([]byte("apiVersion: v1\nkind: ConfigMap\n"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reports every invalid field", func() {
+		_, err := Decode([]byte(`apiVersion: config.dana.io.namespacelabel.com/v1alpha1
+kind: ManagerConfig
+policy:
+  protectedPrefixes: [Example.COM]
+  maxPodSecurityLevel: root
+  allowedSecrets: ["team-["]
+  allowedNamespaceRefs: ["["]
+  keyMigrations:
+  - {from: team, to: dana.io/team}
+  - {from: team, to: owner}
+  - {from: owner, to: "Not A Key"}
+  overrideFieldManagers: ["helm-["]
+  labelQuota:
+    maxLabelsPerObject: -1
+namespaces:
+  selector: "tenant in"
+  watch: [team_a]
+limits:
+  maxConcurrentReconciles: 0
+  requeueBaseDelay: 1m
+  requeueMaxDelay: 1s
+defaults:
+  labels:
+    created-by: "${user}"
+    owner: "not a value"
+`))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(`policy.protectedPrefixes[0]: Invalid value: "Example.COM"`))
+		Expect(err.Error()).To(ContainSubstring(`policy.maxPodSecurityLevel: Unsupported value: "root"`))
+		Expect(err.Error()).To(ContainSubstring(`policy.allowedSecrets[0]: Invalid value: "team-["`))
+		Expect(err.Error()).To(ContainSubstring(`policy.allowedNamespaceRefs[0]: Invalid value: "["`))
+		Expect(err.Error()).To(ContainSubstring(`policy.keyMigrations[1].from: Duplicate value: "team"`))
+		Expect(err.Error()).To(ContainSubstring(`policy.keyMigrations[2].to: Invalid value: "Not A Key"`))
+		Expect(err.Error()).To(ContainSubstring(`policy.overrideFieldManagers[0]: Invalid value: "helm-["`))
+		Expect(err.Error()).To(ContainSubstring(`policy.labelQuota.maxLabelsPerObject: Invalid value: -1`))
+		Expect(err.Error()).To(ContainSubstring(`namespaces.selector: Invalid value: "tenant in"`))
+		Expect(err.Error()).To(ContainSubstring(`namespaces.watch[0]: Invalid value: "team_a"`))
+		Expect(err.Error()).To(ContainSubstring(`limits.maxConcurrentReconciles: Invalid value: 0`))
+		Expect(err.Error()).To(ContainSubstring(`limits.requeueBaseDelay: Invalid value: "1m0s": ` +
+			`must not be longer than requeueMaxDelay`))
+		Expect(err.Error()).To(ContainSubstring(`defaults.labels[owner]: Invalid value: "not a value"`))
+		Expect(err.Error()).NotTo(ContainSubstring("created-by"))
+	})
+
+	It("lists the changes that need a restart", func() {
+		old, err := Decode([]byte(sample))
+		Expect(err).NotTo(HaveOccurred())
+
+		cfg := old.DeepCopy()
+		cfg.Policy.ProtectedPrefixes = nil
+		writes := 5.0
+		cfg.Limits.NamespaceWritesPerSecond = &writes
+		Expect(RestartRequired(old, cfg)).To(BeEmpty())
+
+		cfg.Namespaces.Selector = "tenant=false"
+		cfg.Audit.LogPath = "-"
+		cfg.Defaults.Labels = map[string]string{"tenant": "yes"}
+		Expect(RestartRequired(old, cfg)).To(Equal([]string{"namespaces", "audit"}))
+	})
+
+	It("reloads the file when it is replaced", func() {
+		dir := GinkgoT().TempDir()
+		path := filepath.Join(dir, "config.yaml")
+		Expect(os.WriteFile(path, []byte(sample), 0o600)).To(Succeed())
+
+		changes := make(chan *configv1alpha1.ManagerConfig, 1)
+		watcher := &Watcher{Path: path, OnChange: func(_ context.Context, cfg *configv1alpha1.ManagerConfig) {
+			changes <- cfg
+		}}
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		done := make(chan error)
+		go func() { done <- watcher.Start(ctx) }()
+
+		replace := func(content string) {
+			tmp := filepath.Join(dir, "config.yaml.tmp")
+			Expect(os.WriteFile(tmp, []byte(content), 0o600)).To(Succeed())
+			Expect(os.Rename(tmp, path)).To(Succeed())
+		}
+
+		By("ignoring an invalid file")
+		// Give the watcher time to start before the first write.
+		time.Sleep(100 * time.Millisecond)
+		replace(sample + "bogus: true\n")
+		Consistently(changes, 200*time.Millisecond).ShouldNot(Receive())
+
+		By("passing on a valid change")
+		replace(`apiVersion: config.dana.io.namespacelabel.com/v1alpha1
+kind: ManagerConfig
+policy:
+  protectedPrefixes: []
+`)
+		var cfg *configv1alpha1.ManagerConfig
+		Eventually(changes).Should(Receive(&cfg))
+		Expect(cfg.Policy.ProtectedPrefixes).To(BeEmpty())
+		Expect(cfg.Policy.ProtectedPrefixes).NotTo(BeNil())
+
+		cancel()
+		Eventually(done).Should(Receive(BeNil()))
+	})
+})
@@ -0,0 +1,54 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import "time"
+
+// Limits bounds how hard the controller works the API server.
+type Limits struct {
+	// MaxConcurrentReconciles is the number of NamespaceLabels reconciled
+	// at the same time.
+	MaxConcurrentReconciles int
+
+	// BaseDelay and MaxDelay bound the exponential backoff of a single
+	// NamespaceLabel that keeps failing.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// QPS and Burst size the token bucket shared by all requeues.
+	QPS   float64
+	Burst int
+
+	// NamespaceWritesPerSecond caps the patches sent to Namespaces across
+	// all reconciles, including server-side dry-runs. Zero means no cap.
+	NamespaceWritesPerSecond float64
+	// NamespaceWriteBurst is the number of Namespace writes allowed at once
+	// before the cap applies.
+	NamespaceWriteBurst int
+}
+
+// DefaultLimits returns the controller-runtime defaults, with no cap on
+// Namespace writes.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxConcurrentReconciles: 1,
+		BaseDelay:               5 * time.Millisecond,
+		MaxDelay:                1000 * time.Second,
+		QPS:                     10,
+		Burst:                   100,
+		NamespaceWriteBurst:     1,
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	configv1alpha1 "github.com/TalDebi/namespacelabel/api/config/v1alpha1"
+)
+
+// Watcher reloads the configuration file whenever it changes. It is a
+// manager Runnable that runs on every replica, leader or not.
+type Watcher struct {
+	// Path is the configuration file.
+	Path string
+	// OnChange receives every valid configuration that differs from the
+	// previous one. Invalid files are logged and ignored.
+	OnChange func(ctx context.Context, cfg *configv1alpha1.ManagerConfig)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+func (w *Watcher) NeedLeaderElection() bool {
+	return false
+}
+
+// Start watches the file until ctx is done.
+func (w *Watcher) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("config").WithValues("path", w.Path)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close() //nolint:errcheck
+
+	// Watch the directory: editors and ConfigMap volumes replace the file,
+	// or a symlink to it, rather than writing it in place.
+	if err := watcher.Add(filepath.Dir(w.Path)); err != nil {
+		return err
+	}
+
+	last, err := os.ReadFile(w.Path)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			logger.Error(err, "watching configuration file")
+		case <-watcher.Events:
+			data, err := os.ReadFile(w.Path)
+			if err != nil || bytes.Equal(data, last) {
+				continue
+			}
+			last = data
+			cfg, err := Decode(data)
+			if err != nil {
+				logger.Error(err, "ignoring invalid configuration file")
+				continue
+			}
+			logger.Info("configuration file changed")
+			w.OnChange(ctx, cfg)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+// DefaultLabelsAnnotation lists, comma-separated, the keys of the default
+// labels the Namespace webhook added to a new namespace. It is removed once
+// the NamespaceLabel owning them exists.
+const DefaultLabelsAnnotation = "dana.io.namespacelabel.com/default-labels"
+
+// DefaultsReconciler creates a NamespaceLabel owning the default labels the
+// Namespace webhook added to a new namespace, so they are tracked like any
+// other label. The webhook cannot create it itself: the namespace does not
+// exist yet when it is admitted.
+type DefaultsReconciler struct {
+	client.Client
+
+	// Name is the name of the NamespaceLabel created in each namespace.
+	Name string
+	// DryRun only validates the NamespaceLabel with a server-side dry-run
+	// and leaves the namespace annotated.
+	DryRun bool
+}
+
+// Reconcile creates the NamespaceLabel for the keys listed in the
+// namespace's DefaultLabelsAnnotation, then removes the annotation. An
+// existing NamespaceLabel with the same name is left alone.
+func (r *DefaultsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ns := namespaceMetadata()
+	if err := r.Get(ctx, req.NamespacedName, ns); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	keys, ok := ns.Annotations[DefaultLabelsAnnotation]
+	if !ok || !ns.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	labels := map[string]string{}
+	for _, key := range strings.Split(keys, ",") {
+		if value, ok := ns.Labels[key]; ok && key != "" {
+			labels[key] = value
+		}
+	}
+	if len(labels) > 0 {
+		nl := &danaiov1alpha1.NamespaceLabel{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns.Name, Name: r.Name},
+			Spec:       danaiov1alpha1.NamespaceLabelSpec{Labels: labels},
+		}
+		var opts []client.CreateOption
+		message := "created NamespaceLabel for default labels"
+		if r.DryRun {
+			opts = append(opts, client.DryRunAll)
+			message = "dry-run: would create NamespaceLabel for default labels"
+		}
+		err := r.Create(ctx, nl, opts...)
+		if err != nil && !apierrors.IsAlreadyExists(err) {
+			return ctrl.Result{}, err
+		}
+		if err == nil {
+			log.FromContext(ctx).Info(message, "namespace", ns.Name, "name", r.Name, "keys", plan.SortedKeys(labels))
+		}
+	}
+	if r.DryRun {
+		return ctrl.Result{}, nil
+	}
+
+	patch := client.MergeFrom(ns.DeepCopy())
+	delete(ns.Annotations, DefaultLabelsAnnotation)
+	return ctrl.Result{}, r.Patch(ctx, ns, patch)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *DefaultsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	annotated := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		_, ok := obj.GetAnnotations()[DefaultLabelsAnnotation]
+		return ok
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Namespace{}, builder.OnlyMetadata, builder.WithPredicates(annotated)).
+		Named("namespacedefaults").
+		Complete(r)
+}
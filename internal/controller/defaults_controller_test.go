@@ -0,0 +1,80 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+)
+
+var _ = Describe("Defaults Controller", func() {
+	ctx := context.Background()
+
+	It("creates a NamespaceLabel owning the default labels and drops the annotation", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "defaults-test",
+			Labels:      map[string]string{"created-by": "alice", "tenant": "a", "env": "dev"},
+			Annotations: map[string]string{DefaultLabelsAnnotation: "created-by,tenant"},
+		}}
+		Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+
+		reconciler := &DefaultsReconciler{Client: k8sClient, Name: "defaults"}
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: ns.Name}})
+		Expect(err).NotTo(HaveOccurred())
+
+		var nl danaiov1alpha1.NamespaceLabel
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: ns.Name, Name: "defaults"}, &nl)).To(Succeed())
+		Expect(nl.Spec.Labels).To(Equal(map[string]string{"created-by": "alice", "tenant": "a"}))
+
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: ns.Name}, ns)).To(Succeed())
+		Expect(ns.Annotations).NotTo(HaveKey(DefaultLabelsAnnotation))
+
+		By("leaving the namespace alone once the annotation is gone")
+		Expect(k8sClient.Delete(ctx, &nl)).To(Succeed())
+		_, err = reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: ns.Name}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: ns.Name, Name: "defaults"}, &nl)).NotTo(Succeed())
+	})
+
+	It("writes nothing in dry-run mode", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "defaults-dry-run",
+			Labels:      map[string]string{"tenant": "a"},
+			Annotations: map[string]string{DefaultLabelsAnnotation: "tenant"},
+		}}
+		Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+
+		reconciler := &DefaultsReconciler{Client: k8sClient, Name: "defaults", DryRun: true}
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: ns.Name}})
+		Expect(err).NotTo(HaveOccurred())
+
+		var nl danaiov1alpha1.NamespaceLabel
+		err = k8sClient.Get(ctx, types.NamespacedName{Namespace: ns.Name, Name: "defaults"}, &nl)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Name: ns.Name}, ns)).To(Succeed())
+		Expect(ns.Annotations).To(HaveKey(DefaultLabelsAnnotation))
+	})
+})
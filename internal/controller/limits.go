@@ -0,0 +1,57 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/TalDebi/namespacelabel/internal/config"
+)
+
+// rateLimiter returns the work queue rate limiter for l: the slower of a
+// per-item exponential backoff and an overall token bucket.
+func rateLimiter(l config.Limits) workqueue.TypedRateLimiter[reconcile.Request] {
+	return workqueue.NewTypedMaxOfRateLimiter(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](l.BaseDelay, l.MaxDelay),
+		&workqueue.TypedBucketRateLimiter[reconcile.Request]{Limiter: rate.NewLimiter(rate.Limit(l.QPS), l.Burst)},
+	)
+}
+
+// namespaceWriteLimiter returns the limiter shared by Namespace writes, or
+// nil if they are not capped.
+func namespaceWriteLimiter(l config.Limits) *rate.Limiter {
+	if l.NamespaceWritesPerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(l.NamespaceWritesPerSecond), max(l.NamespaceWriteBurst, 1))
+}
+
+// waitForNamespaceWrite blocks until the Namespace write cap allows another
+// patch, or ctx is done.
+func (r *NamespaceLabelReconciler) waitForNamespaceWrite(ctx context.Context) error {
+	r.mu.RLock()
+	limiter := r.namespaceWrites
+	r.mu.RUnlock()
+	if limiter == nil {
+		return nil
+	}
+	return limiter.Wait(ctx)
+}
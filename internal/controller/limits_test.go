@@ -0,0 +1,75 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/TalDebi/namespacelabel/internal/config"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+var _ = Describe("Limits", func() {
+	It("backs off failing items exponentially up to the max delay", func() {
+		limits := config.DefaultLimits()
+		limits.BaseDelay = time.Second
+		limits.MaxDelay = 3 * time.Second
+		limiter := rateLimiter(limits)
+
+		item := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "a"}}
+		Expect(limiter.When(item)).To(Equal(time.Second))
+		Expect(limiter.When(item)).To(Equal(2 * time.Second))
+		Expect(limiter.When(item)).To(Equal(3 * time.Second))
+
+		limiter.Forget(item)
+		Expect(limiter.When(item)).To(Equal(time.Second))
+	})
+
+	It("caps Namespace writes only when configured", func() {
+		Expect(namespaceWriteLimiter(config.DefaultLimits())).To(BeNil())
+
+		limits := config.Limits{NamespaceWritesPerSecond: 5}
+		limiter := namespaceWriteLimiter(limits)
+		Expect(limiter).NotTo(BeNil())
+		Expect(limiter.Burst()).To(Equal(1))
+		Expect(float64(limiter.Limit())).To(Equal(5.0))
+	})
+
+	It("reloads without blocking when no controller drains the requeue requests", func() {
+		r := &NamespaceLabelReconciler{reloaded: make(chan struct{}, 1)}
+		policy := plan.DefaultPolicy()
+		policy.Quota.MaxLabelsPerObject = 10
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range 3 {
+				r.Reload(policy, config.Limits{NamespaceWritesPerSecond: 5})
+			}
+		}()
+		Eventually(done).Should(BeClosed())
+
+		Expect(r.policy()).To(Equal(policy))
+		Expect(r.namespaceWrites).NotTo(BeNil())
+		Expect(r.reloaded).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,84 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/metrics"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+var _ = Describe("recordMetrics", func() {
+	BeforeEach(func() {
+		for _, counter := range []interface{ Reset() }{metrics.LabelsApplied, metrics.LabelsRemoved,
+			metrics.LabelsRejected, metrics.Conflicts, metrics.DriftCorrections, metrics.PolicyViolations} {
+			counter.Reset()
+		}
+	})
+
+	It("counts changes and each new rejection once", func() {
+		rejected := func(key, reason string) danaiov1alpha1.RejectedLabel {
+			return danaiov1alpha1.RejectedLabel{Key: key, Reason: reason, Message: "rejected"}
+		}
+		nl := &danaiov1alpha1.NamespaceLabel{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "labels"},
+			Status: danaiov1alpha1.NamespaceLabelStatus{
+				RejectedLabels: []danaiov1alpha1.RejectedLabel{rejected("kubernetes.io/old", danaiov1alpha1.RejectedReasonProtected)},
+			},
+		}
+		recordMetrics(nl, plan.Plan{
+			Add:     []plan.Change{{Key: "team", New: "a"}, {Key: "tier", New: "gold"}},
+			Update:  []plan.Change{{Key: "env", Old: "dev", New: "prod"}},
+			Remove:  []plan.Change{{Key: "owner", Old: "alice"}},
+			Drifted: []string{"env"},
+			Rejected: []danaiov1alpha1.RejectedLabel{
+				rejected("kubernetes.io/old", danaiov1alpha1.RejectedReasonProtected),
+				rejected("kubernetes.io/new", danaiov1alpha1.RejectedReasonProtected),
+				rejected("pod-security.kubernetes.io/enforce", danaiov1alpha1.RejectedReasonTooPrivileged),
+				rejected("secret", danaiov1alpha1.RejectedReasonForbiddenSource),
+				rejected("shared", danaiov1alpha1.RejectedReasonConflict),
+			},
+		})
+
+		Expect(testutil.CollectAndCompare(metrics.LabelsApplied, strings.NewReader(`
+# HELP namespacelabel_labels_applied_total Number of labels added to or updated on a namespace.
+# TYPE namespacelabel_labels_applied_total counter
+namespacelabel_labels_applied_total{namespace="team-a"} 3
+`))).To(Succeed())
+		Expect(testutil.ToFloat64(metrics.LabelsRemoved.WithLabelValues("team-a"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(metrics.DriftCorrections.WithLabelValues("team-a"))).To(Equal(1.0))
+
+		By("counting the rejection already in the status only once")
+		Expect(testutil.CollectAndCompare(metrics.LabelsRejected, strings.NewReader(`
+# HELP namespacelabel_labels_rejected_total Number of requested labels that were not applied, by reason.
+# TYPE namespacelabel_labels_rejected_total counter
+namespacelabel_labels_rejected_total{namespace="team-a",reason="Conflict"} 1
+namespacelabel_labels_rejected_total{namespace="team-a",reason="ForbiddenSource"} 1
+namespacelabel_labels_rejected_total{namespace="team-a",reason="Protected"} 1
+namespacelabel_labels_rejected_total{namespace="team-a",reason="TooPrivileged"} 1
+`))).To(Succeed())
+		Expect(testutil.ToFloat64(metrics.Conflicts.WithLabelValues("team-a"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(metrics.PolicyViolations.WithLabelValues("team-a"))).To(Equal(3.0))
+	})
+})
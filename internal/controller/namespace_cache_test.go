@@ -0,0 +1,177 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+// namespaceCount is the number of namespaces held by the benchmark stores.
+const namespaceCount = 10000
+
+// BenchmarkNamespaceCache reports the heap held by an informer store of 10k
+// namespaces when full objects are cached, and when they are cached as
+// metadata trimmed by trimNamespace, as cmd/main.go configures the manager
+// cache through NamespaceCache: about 18.8 MB against 9.4 MB. Run it without the envtest suite:
+//
+//	go test ./internal/controller -run '^$' -bench NamespaceCache -benchmem
+func BenchmarkNamespaceCache(b *testing.B) {
+	docs := make([][]byte, namespaceCount)
+	for i := range docs {
+		docs[i] = namespaceJSON(b, i)
+	}
+
+	b.Run("Full", func(b *testing.B) {
+		benchmarkStore(b, docs, func(doc []byte) (any, error) {
+			ns := &corev1.Namespace{}
+			return ns, json.Unmarshal(doc, ns)
+		})
+	})
+	b.Run("Metadata", func(b *testing.B) {
+		transform := NamespaceCache(plan.NamespaceFilter{}).Transform
+		benchmarkStore(b, docs, func(doc []byte) (any, error) {
+			ns := namespaceMetadata()
+			if err := json.Unmarshal(doc, ns); err != nil {
+				return nil, err
+			}
+			return transform(ns)
+		})
+	})
+}
+
+// benchmarkStore fills a store with the decoded docs and reports the heap
+// it retains.
+func benchmarkStore(b *testing.B, docs [][]byte, decode func([]byte) (any, error)) {
+	b.ReportAllocs()
+	var retained int64
+	for n := 0; n < b.N; n++ {
+		before := heapAlloc()
+		store := toolscache.NewStore(toolscache.MetaNamespaceKeyFunc)
+		for _, doc := range docs {
+			obj, err := decode(doc)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := store.Add(obj); err != nil {
+				b.Fatal(err)
+			}
+		}
+		retained += heapAlloc() - before
+		runtime.KeepAlive(store)
+	}
+	b.ReportMetric(float64(retained)/float64(b.N), "B/10k-namespaces")
+}
+
+func heapAlloc() int64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return int64(stats.HeapAlloc)
+}
+
+// namespaceJSON returns the i-th namespace as the API server would send it,
+// with the labels, last-applied annotation and managedFields typical of a
+// namespace created with kubectl apply and labelled by the operator.
+func namespaceJSON(tb testing.TB, i int) []byte {
+	name := fmt.Sprintf("tenant-%05d", i)
+	labels := map[string]string{
+		"kubernetes.io/metadata.name": name,
+		"team":                        fmt.Sprintf("team-%d", i%200),
+		"cost-center":                 fmt.Sprintf("cc-%04d", i%1000),
+		"tier":                        "gold",
+	}
+	lastApplied, err := json.Marshal(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   map[string]any{"name": name, "labels": labels},
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	now := metav1.Now()
+	ns := corev1.Namespace{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			UID:               "d9607e19-f88f-11e6-a518-42010a800195",
+			ResourceVersion:   fmt.Sprint(100000 + i),
+			CreationTimestamp: now,
+			Labels:            labels,
+			Annotations: map[string]string{
+				"kubectl.kubernetes.io/last-applied-configuration": string(lastApplied),
+			},
+			ManagedFields: []metav1.ManagedFieldsEntry{{
+				Manager:    "kubectl-client-side-apply",
+				Operation:  metav1.ManagedFieldsOperationUpdate,
+				APIVersion: "v1",
+				Time:       &now,
+				FieldsType: "FieldsV1",
+				FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:annotations":{".":{},` +
+					`"f:kubectl.kubernetes.io/last-applied-configuration":{}},"f:labels":{".":{},` +
+					`"f:kubernetes.io/metadata.name":{}}}}`)},
+			}, {
+				Manager:    plan.FieldManager,
+				Operation:  metav1.ManagedFieldsOperationUpdate,
+				APIVersion: "v1",
+				Time:       &now,
+				FieldsType: "FieldsV1",
+				FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:cost-center":{},` +
+					`"f:team":{},"f:tier":{}}}}`)},
+			}},
+		},
+		Spec:   corev1.NamespaceSpec{Finalizers: []corev1.FinalizerName{corev1.FinalizerKubernetes}},
+		Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+	}
+	doc, err := json.Marshal(ns)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return doc
+}
+
+var _ = Describe("trimNamespace", func() {
+	It("keeps only the annotations and label ownership the controller reads", func() {
+		ns := namespaceMetadata()
+		Expect(json.Unmarshal(namespaceJSON(GinkgoTB(), 0), ns)).To(Succeed())
+		ns.Annotations[DefaultLabelsAnnotation] = "team"
+
+		_, err := trimNamespace(ns)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(ns.Annotations).To(Equal(map[string]string{DefaultLabelsAnnotation: "team"}))
+		Expect(ns.ManagedFields).To(HaveLen(1))
+		Expect(ns.ManagedFields[0].Manager).To(Equal("kubectl-client-side-apply"))
+		Expect(string(ns.ManagedFields[0].FieldsV1.Raw)).To(
+			Equal(`{"f:metadata":{"f:labels":{"f:kubernetes.io/metadata.name":{}}}}`))
+		Expect(plan.DefaultPolicy().ForeignOwners(ns)).To(BeEmpty())
+		Expect(plan.Policy{}.ForeignOwners(ns)).To(Equal(map[string][]string{
+			"kubernetes.io/metadata.name": {"kubectl-client-side-apply"},
+		}))
+	})
+})
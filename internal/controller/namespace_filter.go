@@ -0,0 +1,129 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+// namespacePredicate drops events for namespaces excluded by name. Informers
+// cannot filter on patterns, so only exact names are left out of the cache.
+func namespacePredicate(f plan.NamespaceFilter) predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		_, excluded := f.ExcludedBy(obj.GetName())
+		return !excluded
+	})
+}
+
+// namespaceFieldSelector returns the selector leaving out the namespaces
+// excluded by an exact name, or nil if there are none.
+func namespaceFieldSelector(f plan.NamespaceFilter) fields.Selector {
+	var selectors []fields.Selector
+	for _, pattern := range f.Exclude {
+		if !strings.ContainsAny(pattern, `*?[\`) {
+			selectors = append(selectors, fields.OneTermNotEqualSelector("metadata.name", pattern))
+		}
+	}
+	if len(selectors) == 0 {
+		return nil
+	}
+	return fields.AndSelectors(selectors...)
+}
+
+// NamespaceCache returns the cache settings for Namespaces: informers only
+// hold namespaces matching the filter's selector and not excluded by exact
+// name, and objects are trimmed by trimNamespace before they are stored.
+func NamespaceCache(filter plan.NamespaceFilter) cache.ByObject {
+	return cache.ByObject{
+		Label:     filter.Selector,
+		Field:     namespaceFieldSelector(filter),
+		Transform: trimNamespace,
+	}
+}
+
+// lastAppliedAnnotation holds the whole manifest of objects created with
+// kubectl apply, often the largest part of a namespace's metadata.
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// labelsField marks the managedFields entries that own labels.
+var labelsField = []byte(`"f:labels"`)
+
+// trimNamespace drops what the controller never reads from a cached
+// namespace: the last-applied annotation, the operator's own managedFields
+// entries and every other entry that owns no label. The entries kept are cut
+// down to the manager and the labels it owns, which is all
+// plan.Policy.ForeignOwners reads. The object is changed in place.
+func trimNamespace(obj any) (any, error) {
+	accessor, ok := obj.(metav1.Object)
+	if !ok {
+		return obj, nil
+	}
+	if annotations := accessor.GetAnnotations(); annotations != nil {
+		delete(annotations, lastAppliedAnnotation)
+		if len(annotations) == 0 {
+			accessor.SetAnnotations(nil)
+		}
+	}
+
+	var kept []metav1.ManagedFieldsEntry
+	for _, entry := range accessor.GetManagedFields() {
+		if entry.Manager == plan.FieldManager || entry.FieldsV1 == nil ||
+			!bytes.Contains(entry.FieldsV1.Raw, labelsField) {
+			continue
+		}
+		keys := plan.ManagedLabelKeys(entry)
+		if len(keys) == 0 {
+			continue
+		}
+		entry.FieldsV1 = &metav1.FieldsV1{Raw: labelFields(keys)}
+		entry.APIVersion, entry.Time, entry.FieldsType = "", nil, ""
+		kept = append(kept, entry)
+	}
+	accessor.SetManagedFields(slices.Clip(kept))
+	return obj, nil
+}
+
+// labelFields returns the FieldsV1 of an entry owning keys and nothing else.
+// Label keys never need escaping in JSON.
+func labelFields(keys []string) []byte {
+	const prefix, suffix = `{"f:metadata":{"f:labels":{`, "}}}"
+	size := len(prefix) + len(suffix)
+	for _, key := range keys {
+		size += len(key) + len(`,"f:":{}`)
+	}
+	buf := make([]byte, 0, size)
+	buf = append(buf, prefix...)
+	for i, key := range keys {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, `"f:`...)
+		buf = append(buf, key...)
+		buf = append(buf, `":{}`...)
+	}
+	return append(buf, suffix...)
+}
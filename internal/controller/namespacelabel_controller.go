@@ -18,46 +18,789 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/audit"
+	"github.com/TalDebi/namespacelabel/internal/config"
+	"github.com/TalDebi/namespacelabel/internal/health"
+	"github.com/TalDebi/namespacelabel/internal/metrics"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+	"github.com/TalDebi/namespacelabel/internal/sources"
+	"github.com/TalDebi/namespacelabel/internal/tracing"
 )
 
+// finalizerName guards removal of the labels a NamespaceLabel applied.
+const finalizerName = "dana.io.namespacelabel.com/finalizer"
+
+// ControllerName names the controller, its workqueue and its metrics.
+const ControllerName = "namespacelabel"
+
 // NamespaceLabelReconciler reconciles a NamespaceLabel object
 type NamespaceLabelReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Policy decides which requested labels may be applied. Use Reload to
+	// change it once the controller runs.
+	Policy plan.Policy
+
+	// Audit, if set, receives one event per namespace label mutation.
+	Audit audit.Sink
+
+	// Recorder emits Kubernetes events about planned changes.
+	Recorder record.EventRecorder
+
+	// DryRun computes and reports changes without writing to Namespaces.
+	DryRun bool
+
+	// WatchNamespaces, if set, restricts the Namespace watch to these names
+	// so the controller only needs access to them. Each name gets its own
+	// cache, since a field selector cannot match several names.
+	WatchNamespaces []string
+
+	// Namespaces selects the namespaces whose NamespaceLabels are managed.
+	// NamespaceLabels elsewhere are refused with a Ready=False condition.
+	Namespaces plan.NamespaceFilter
+
+	// Limits bounds concurrency, requeue rate and Namespace writes. The zero
+	// value uses config.DefaultLimits.
+	Limits config.Limits
+
+	// Progress, if set, is told about every reconcile so a liveness probe
+	// can detect a stuck loop.
+	Progress *health.Progress
+
+	// APIReader reads the sources of spec.labelsFrom, and objects again
+	// after a propagation patch conflicts. It should bypass the cache, which
+	// only holds the metadata of ConfigMaps and Secrets and may be stale.
+	// Defaults to the Client.
+	APIReader client.Reader
+
+	// namespaceCaches are the per-name caches created for WatchNamespaces,
+	// or the unfiltered cache of the namespaces spec.labelsFrom may read.
+	namespaceCaches []cache.Cache
+
+	// mu guards Policy and namespaceWrites against Reload.
+	mu              sync.RWMutex
+	namespaceWrites *rate.Limiter
+	// reloaded holds a pending request from Reload to requeue every
+	// NamespaceLabel. It is buffered so Reload never blocks, including on
+	// replicas that are not the leader and never run the controller.
+	reloaded chan struct{}
 }
 
 // +kubebuilder:rbac:groups=dana.io.namespacelabel.com,resources=namespacelabels,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=dana.io.namespacelabel.com,resources=namespacelabels/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=dana.io.namespacelabel.com,resources=namespacelabels/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=configmaps;secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=dana.io.namespacelabel.com,resources=namespaceprofiles,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=resourcequotas;limitranges,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;delete
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the NamespaceLabel object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// Reconcile applies the labels requested by a NamespaceLabel to the namespace
+// it lives in. Keys rejected by the policy or already owned by another
+// NamespaceLabel in the same namespace are reported in the status instead.
+// Labels the object no longer requests, or that it owned when it is deleted,
+// are removed from the namespace.
+//
+// When the object is in Plan mode, or the controller runs in dry-run mode, the
+// changes are validated with a server-side dry-run patch and reported in the
+// status and as an event, but never persisted.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.19.1/pkg/reconcile
-func (r *NamespaceLabelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+func (r *NamespaceLabelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	ctx, span := tracing.Start(ctx, "NamespaceLabel.Reconcile",
+		attribute.String("k8s.namespace", req.Namespace),
+		attribute.String("k8s.name", req.Name))
+	defer func() { tracing.End(span, err) }()
+	if r.Progress != nil {
+		r.Progress.Started()
+		defer func() { r.Progress.Finished(err) }()
+	}
+
+	return r.reconcile(ctx, req)
+}
+
+func (r *NamespaceLabelReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	var nl danaiov1alpha1.NamespaceLabel
+	if err := r.Get(ctx, req.NamespacedName, &nl); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if pattern, excluded := r.Namespaces.ExcludedBy(nl.Namespace); excluded {
+		return ctrl.Result{}, r.refuse(ctx, &nl,
+			fmt.Sprintf("Namespace %s matches the excluded pattern %q", nl.Namespace, pattern))
+	}
+
+	notSelected := func() error {
+		return r.refuse(ctx, &nl,
+			fmt.Sprintf("Namespace %s does not match the namespace selector %q", nl.Namespace, r.Namespaces.Selector))
+	}
+	ns := namespaceMetadata()
+	err := r.Get(ctx, client.ObjectKey{Name: nl.Namespace}, ns)
+	switch {
+	case apierrors.IsNotFound(err) && !nl.DeletionTimestamp.IsZero():
+		// The namespace is already gone, so there is nothing left to clean up.
+		controllerutil.RemoveFinalizer(&nl, finalizerName)
+		return ctrl.Result{}, r.Update(ctx, &nl)
+	case apierrors.IsNotFound(err) && r.Namespaces.Selector != nil:
+		// The cache only holds namespaces matching the selector.
+		return ctrl.Result{}, notSelected()
+	case err != nil:
+		return ctrl.Result{}, err
+	case !r.Namespaces.Selects(ns.Labels):
+		return ctrl.Result{}, notSelected()
+	}
+
+	if !nl.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalize(ctx, &nl, ns)
+	}
+
+	// Nothing is written to the namespace in dry-run mode, so there is
+	// nothing for a finalizer to clean up either.
+	if !r.DryRun && controllerutil.AddFinalizer(&nl, finalizerName) {
+		if err := r.Update(ctx, &nl); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	var siblings danaiov1alpha1.NamespaceLabelList
+	if err := r.List(ctx, &siblings, client.InNamespace(nl.Namespace)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	policy := r.policy()
+	resolved, err := sources.Resolve(ctx, r.sourceReader(), &nl, policy)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	p := plan.ForNamespaceLabel(&nl, ns, siblings.Items, resolved, policy)
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("plan.added", len(p.Add)),
+		attribute.Int("plan.updated", len(p.Update)),
+		attribute.Int("plan.removed", len(p.Remove)),
+		attribute.Int("plan.rejected", len(p.Rejected)))
+
+	switch {
+	case r.DryRun:
+		return ctrl.Result{}, r.reportPlan(ctx, &nl, ns, p, "DryRun")
+	case nl.Spec.Mode == danaiov1alpha1.ModePlan:
+		return ctrl.Result{}, r.reportPlan(ctx, &nl, ns, p, "Planned")
+	}
+
+	if err := r.applyPlan(ctx, ns, p); err != nil {
+		return ctrl.Result{}, err
+	}
+	// The namespace is patched, so a retry would find nothing left to do:
+	// the change is recorded before anything else can fail.
+	recordMetrics(&nl, p)
+	r.recordAudit(ctx, &nl, p, false)
+	if !p.Empty() {
+		logger.Info("updated namespace labels", "namespace", ns.Name,
+			"added", len(p.Add), "updated", len(p.Update), "removed", len(p.Remove))
+	}
+	propagated, err := r.propagate(ctx, &nl, p.Applied, false)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	nl.Status.PropagatedLabels = propagated
+	// A profile that cannot be applied is reported in the status, and the
+	// error requeues the object with backoff.
+	profiles, profilesErr := r.applyProfiles(ctx, &nl, p.Applied)
+	if profilesErr == nil {
+		nl.Status.Profiles = profiles
+	}
+
+	if err := r.updateStatus(ctx, &nl, p, profilesErr); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, profilesErr
+}
+
+// finalize removes the labels owned by nl from ns and releases the finalizer.
+func (r *NamespaceLabelReconciler) finalize(ctx context.Context, nl *danaiov1alpha1.NamespaceLabel,
+	ns *metav1.PartialObjectMetadata) error {
+	if !controllerutil.ContainsFinalizer(nl, finalizerName) {
+		return nil
+	}
+	if r.DryRun {
+		r.event(nl, corev1.EventTypeWarning, "DryRun",
+			fmt.Sprintf("Dry-run mode: leaving %d owned label(s) on the namespace", len(nl.Status.AppliedLabels)))
+		controllerutil.RemoveFinalizer(nl, finalizerName)
+		return r.Update(ctx, nl)
+	}
+
+	policy := r.policy()
+	p := plan.Compute(plan.Input{
+		Current:       ns.Labels,
+		Owned:         nl.Status.AppliedLabels,
+		ForeignOwners: policy.ForeignOwners(ns),
+	}, policy)
+	if err := r.applyPlan(ctx, ns, p); err != nil {
+		return err
+	}
+	recordMetrics(nl, p)
+	r.recordAudit(ctx, nl, p, true)
+	if _, err := r.propagate(ctx, nl, nil, true); err != nil {
+		return err
+	}
+
+	controllerutil.RemoveFinalizer(nl, finalizerName)
+	return r.Update(ctx, nl)
+}
+
+// applyPlan patches the labels of ns according to p. The patch carries the
+// resourceVersion so concurrent writers cause a conflict and a retry instead
+// of a lost update.
+func (r *NamespaceLabelReconciler) applyPlan(ctx context.Context, ns *metav1.PartialObjectMetadata, p plan.Plan) error {
+	if p.Empty() {
+		return nil
+	}
+	if err := r.waitForNamespaceWrite(ctx); err != nil {
+		return err
+	}
+	patch := client.MergeFromWithOptions(ns.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	ns.Labels = p.ApplyTo(ns.Labels)
+	return r.Patch(ctx, ns, patch, client.FieldOwner(plan.FieldManager))
+}
+
+// reportPlan validates p against the API server without persisting it, then
+// records it in the status of nl and as an event with the given reason.
+func (r *NamespaceLabelReconciler) reportPlan(ctx context.Context, nl *danaiov1alpha1.NamespaceLabel,
+	ns *metav1.PartialObjectMetadata, p plan.Plan, reason string) error {
+	if !p.Empty() {
+		if err := r.waitForNamespaceWrite(ctx); err != nil {
+			return err
+		}
+		patch := client.MergeFrom(ns.DeepCopy())
+		ns.Labels = p.ApplyTo(ns.Labels)
+		if err := r.Patch(ctx, ns, patch, client.DryRunAll, client.FieldOwner(plan.FieldManager)); err != nil {
+			return fmt.Errorf("server-side dry-run of namespace patch: %w", err)
+		}
+	}
+	if !p.Empty() || len(p.Rejected) > 0 {
+		r.event(nl, corev1.EventTypeNormal, reason, fmt.Sprintf(
+			"Would add %d, update %d and remove %d label(s); %d rejected",
+			len(p.Add), len(p.Update), len(p.Remove), len(p.Rejected)))
+	}
+
+	r.reportDeprecated(nl, p)
+	reportForeignOwners(nl, p)
+	nl.Status.Plan = planStatus(p)
+	nl.Status.RejectedLabels = p.Rejected
+	nl.Status.ObservedGeneration = nl.Generation
+
+	cond := metav1.Condition{
+		Type:               danaiov1alpha1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             reason,
+		Message:            "Changes are planned but not applied",
+		ObservedGeneration: nl.Generation,
+	}
+	switch {
+	case p.QuotaExceeded != "":
+		cond.Reason = "QuotaExceeded"
+		cond.Message = "No labels are applied: the NamespaceLabel " + p.QuotaExceeded
+	case len(p.Rejected) > 0:
+		cond.Reason = "LabelsRejected"
+		cond.Message = fmt.Sprintf("%d requested label(s) were rejected", len(p.Rejected))
+	case p.Empty():
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = "UpToDate"
+		cond.Message = "The namespace already carries all requested labels"
+	}
+	meta.SetStatusCondition(&nl.Status.Conditions, cond)
+
+	return r.Status().Update(ctx, nl)
+}
+
+// refuse reports on nl that its namespace is not managed. Nothing is written
+// to the namespace; a NamespaceLabel being deleted just loses its finalizer,
+// since the labels it guards are no longer the operator's to remove.
+func (r *NamespaceLabelReconciler) refuse(ctx context.Context, nl *danaiov1alpha1.NamespaceLabel,
+	message string) error {
+	if !nl.DeletionTimestamp.IsZero() {
+		if controllerutil.RemoveFinalizer(nl, finalizerName) {
+			return r.Update(ctx, nl)
+		}
+		return nil
+	}
+
+	nl.Status.ObservedGeneration = nl.Generation
+	changed := meta.SetStatusCondition(&nl.Status.Conditions, metav1.Condition{
+		Type:               danaiov1alpha1.ConditionReady,
+		Status:             metav1.ConditionFalse,
+		Reason:             "NamespaceExcluded",
+		Message:            message,
+		ObservedGeneration: nl.Generation,
+	})
+	if changed {
+		r.event(nl, corev1.EventTypeWarning, "NamespaceExcluded", message)
+	}
+	return r.Status().Update(ctx, nl)
+}
+
+// planStatus converts p to its API form, or nil if it neither changes nor
+// rejects anything.
+func planStatus(p plan.Plan) *danaiov1alpha1.LabelPlan {
+	if p.Empty() && len(p.Rejected) == 0 {
+		return nil
+	}
+	convert := func(changes []plan.Change) []danaiov1alpha1.LabelChange {
+		out := make([]danaiov1alpha1.LabelChange, 0, len(changes))
+		for _, c := range changes {
+			out = append(out, danaiov1alpha1.LabelChange{Key: c.Key, OldValue: c.Old, NewValue: c.New})
+		}
+		return out
+	}
+	return &danaiov1alpha1.LabelPlan{
+		Add:      convert(p.Add),
+		Update:   convert(p.Update),
+		Remove:   convert(p.Remove),
+		Rejected: p.Rejected,
+	}
+}
 
-	// TODO(user): your logic here
+// event emits a Kubernetes event on nl if a recorder is configured.
+func (r *NamespaceLabelReconciler) event(nl *danaiov1alpha1.NamespaceLabel, eventType, reason, message string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(nl, eventType, reason, message)
+	}
+}
+
+// reportDeprecated records the deprecated keys of p on nl and emits a warning
+// for each one that was not reported before.
+func (r *NamespaceLabelReconciler) reportDeprecated(nl *danaiov1alpha1.NamespaceLabel, p plan.Plan) {
+	policy := r.policy()
+	for _, key := range p.Deprecated {
+		if slices.Contains(nl.Status.DeprecatedKeys, key) {
+			continue
+		}
+		replacement, _ := policy.Replacement(key)
+		r.event(nl, corev1.EventTypeWarning, "DeprecatedKey", fmt.Sprintf(
+			"Label key %s is deprecated and also written as %s; run kubectl nslabel migrate to move the spec",
+			key, replacement))
+	}
+	nl.Status.DeprecatedKeys = p.Deprecated
+}
+
+// reportForeignOwners records on nl the requested keys that other field
+// managers own on the namespace, and sets the ForeignOwner condition.
+func reportForeignOwners(nl *danaiov1alpha1.NamespaceLabel, p plan.Plan) {
+	nl.Status.ForeignOwners = p.ForeignOwners
+	cond := metav1.Condition{
+		Type:               danaiov1alpha1.ConditionForeignOwner,
+		Status:             metav1.ConditionFalse,
+		Reason:             "NoForeignOwners",
+		Message:            "No other field manager owns a requested label",
+		ObservedGeneration: nl.Generation,
+	}
+	if len(p.ForeignOwners) > 0 {
+		owners := make([]string, 0, len(p.ForeignOwners))
+		for _, owner := range p.ForeignOwners {
+			owners = append(owners, fmt.Sprintf("%s (%s)", owner.Key, strings.Join(owner.Managers, ", ")))
+		}
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = "KeysOwnedElsewhere"
+		cond.Message = "Requested labels also owned by other field managers: " + strings.Join(owners, "; ")
+	}
+	meta.SetStatusCondition(&nl.Status.Conditions, cond)
+}
+
+// updateStatus records the outcome of p on nl, and profilesErr if the
+// NamespaceProfiles could not be applied.
+func (r *NamespaceLabelReconciler) updateStatus(ctx context.Context, nl *danaiov1alpha1.NamespaceLabel,
+	p plan.Plan, profilesErr error) error {
+	r.reportDeprecated(nl, p)
+	reportForeignOwners(nl, p)
+	nl.Status.AppliedLabels = p.Applied
+	nl.Status.RejectedLabels = p.Rejected
+	nl.Status.Plan = nil
+	nl.Status.ObservedGeneration = nl.Generation
 
-	return ctrl.Result{}, nil
+	cond := metav1.Condition{
+		Type:               danaiov1alpha1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Applied",
+		Message:            "All requested labels are applied",
+		ObservedGeneration: nl.Generation,
+	}
+	switch {
+	case p.QuotaExceeded != "":
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "QuotaExceeded"
+		cond.Message = "No labels are applied: the NamespaceLabel " + p.QuotaExceeded
+	case profilesErr != nil:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "ProfilesFailed"
+		cond.Message = "The labels are applied but the NamespaceProfiles are not: " + profilesErr.Error()
+	case len(p.Rejected) > 0:
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = "LabelsRejected"
+		cond.Message = fmt.Sprintf("%d requested label(s) were rejected", len(p.Rejected))
+	}
+	if meta.SetStatusCondition(&nl.Status.Conditions, cond) &&
+		(cond.Reason == "QuotaExceeded" || cond.Reason == "ProfilesFailed") {
+		r.event(nl, corev1.EventTypeWarning, cond.Reason, cond.Message)
+	}
+
+	return r.Status().Update(ctx, nl)
+}
+
+// recordMetrics updates the reconciliation metrics for p. Rejections are only
+// counted the first time they are observed so a stuck key does not inflate
+// the counters on every pass.
+func recordMetrics(nl *danaiov1alpha1.NamespaceLabel, p plan.Plan) {
+	namespace := nl.Namespace
+	metrics.LabelsApplied.WithLabelValues(namespace).Add(float64(len(p.Add) + len(p.Update)))
+	metrics.LabelsRemoved.WithLabelValues(namespace).Add(float64(len(p.Remove)))
+	metrics.DriftCorrections.WithLabelValues(namespace).Add(float64(len(p.Drifted)))
+
+	seen := map[danaiov1alpha1.RejectedLabel]bool{}
+	for _, rejected := range nl.Status.RejectedLabels {
+		seen[danaiov1alpha1.RejectedLabel{Key: rejected.Key, Reason: rejected.Reason}] = true
+	}
+	for _, rejected := range p.Rejected {
+		if seen[danaiov1alpha1.RejectedLabel{Key: rejected.Key, Reason: rejected.Reason}] {
+			continue
+		}
+		metrics.LabelsRejected.WithLabelValues(namespace, rejected.Reason).Inc()
+		switch rejected.Reason {
+		case danaiov1alpha1.RejectedReasonConflict:
+			metrics.Conflicts.WithLabelValues(namespace).Inc()
+		case danaiov1alpha1.RejectedReasonProtected, danaiov1alpha1.RejectedReasonTooPrivileged,
+			danaiov1alpha1.RejectedReasonForbiddenSource:
+			metrics.PolicyViolations.WithLabelValues(namespace).Inc()
+		}
+	}
+}
+
+// recordAudit writes one audit event per change in p. A failure to record is
+// logged rather than returned: the namespace is already patched, so retrying
+// the reconcile would not produce the events again.
+func (r *NamespaceLabelReconciler) recordAudit(ctx context.Context, nl *danaiov1alpha1.NamespaceLabel,
+	p plan.Plan, finalizing bool) {
+	if r.Audit == nil || p.Empty() {
+		return
+	}
+
+	now := time.Now().UTC()
+	user := audit.RequestedBy(nl)
+	drifted := map[string]bool{}
+	for _, key := range p.Drifted {
+		drifted[key] = true
+	}
+
+	events := make([]audit.Event, 0, len(p.Add)+len(p.Update)+len(p.Remove))
+	newEvent := func(key string, oldValue, newValue *string, reason string) audit.Event {
+		return audit.Event{
+			Timestamp:      now,
+			Namespace:      nl.Namespace,
+			NamespaceLabel: nl.Name,
+			Key:            key,
+			OldValue:       oldValue,
+			NewValue:       newValue,
+			User:           user,
+			Reason:         reason,
+		}
+	}
+	for _, c := range p.Add {
+		reason := audit.ReasonAdded
+		if drifted[c.Key] {
+			reason = audit.ReasonDriftCorrected
+		}
+		events = append(events, newEvent(c.Key, nil, &c.New, reason))
+	}
+	for _, c := range p.Update {
+		reason := audit.ReasonUpdated
+		if drifted[c.Key] {
+			reason = audit.ReasonDriftCorrected
+		}
+		events = append(events, newEvent(c.Key, &c.Old, &c.New, reason))
+	}
+	for _, c := range p.Remove {
+		reason := audit.ReasonRemoved
+		if finalizing {
+			reason = audit.ReasonFinalized
+		}
+		events = append(events, newEvent(c.Key, &c.Old, nil, reason))
+	}
+
+	if err := r.Audit.Record(events...); err != nil {
+		log.FromContext(ctx).Error(err, "unable to record audit events", "count", len(events))
+	}
+}
+
+// requestsForNamespace maps a Namespace to every NamespaceLabel inside it, so
+// outside edits to its labels are reconciled back.
+func (r *NamespaceLabelReconciler) requestsForNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.requestsMatching(ctx, client.InNamespace(obj.GetName()))
+}
+
+// requestsForSource returns a map function from an object of the given kind
+// to every NamespaceLabel whose spec.labelsFrom reads it.
+func (r *NamespaceLabelReconciler) requestsForSource(kind string) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		return r.requestsMatching(ctx,
+			client.MatchingFields{sources.IndexField: sources.Key(kind, obj.GetNamespace(), obj.GetName())})
+	}
+}
+
+// requestsForPropagation maps an object to the NamespaceLabels in its
+// namespace that propagate labels, so new objects get them and outside edits
+// are reconciled back.
+func (r *NamespaceLabelReconciler) requestsForPropagation(ctx context.Context, obj client.Object) []reconcile.Request {
+	var list danaiov1alpha1.NamespaceLabelList
+	if err := r.List(ctx, &list, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list NamespaceLabels", "namespace", obj.GetNamespace())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range list.Items {
+		nl := &list.Items[i]
+		if nl.Spec.Propagate != nil || len(nl.Status.PropagatedLabels) > 0 {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(nl)})
+		}
+	}
+	return requests
+}
+
+// requestsMatching lists the NamespaceLabels matching opts as requests.
+func (r *NamespaceLabelReconciler) requestsMatching(ctx context.Context, opts ...client.ListOption) []reconcile.Request {
+	var list danaiov1alpha1.NamespaceLabelList
+	if err := r.List(ctx, &list, opts...); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list NamespaceLabels")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+	}
+	return requests
+}
+
+// sourceReader returns the reader for spec.labelsFrom sources and for
+// objects whose cached copy turned out to be stale.
+func (r *NamespaceLabelReconciler) sourceReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// policy returns the current label policy.
+func (r *NamespaceLabelReconciler) policy() plan.Policy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.Policy
+}
+
+// Reload replaces the label policy and the Namespace write cap of a running
+// controller, then asks for every NamespaceLabel to be requeued so the new
+// policy applies. It does not wait for the requeue, which only happens on the
+// leader. The other fields of limits only take effect in SetupWithManager.
+func (r *NamespaceLabelReconciler) Reload(policy plan.Policy, limits config.Limits) {
+	r.mu.Lock()
+	r.Policy = policy
+	switch {
+	case limits.NamespaceWritesPerSecond <= 0:
+		r.namespaceWrites = nil
+	case r.namespaceWrites == nil:
+		r.namespaceWrites = namespaceWriteLimiter(limits)
+	default:
+		r.namespaceWrites.SetLimit(rate.Limit(limits.NamespaceWritesPerSecond))
+		r.namespaceWrites.SetBurst(max(limits.NamespaceWriteBurst, 1))
+	}
+	r.mu.Unlock()
+
+	// A request already pending covers this one too.
+	select {
+	case r.reloaded <- struct{}{}:
+	default:
+	}
+}
+
+// requeueOnReload is the source feeding the controller every NamespaceLabel
+// whenever Reload asks for it. Sources only start on the leader.
+func (r *NamespaceLabelReconciler) requeueOnReload(ctx context.Context,
+	queue workqueue.TypedRateLimitingInterface[reconcile.Request]) error {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.reloaded:
+			}
+			var list danaiov1alpha1.NamespaceLabelList
+			if err := r.List(ctx, &list); err != nil {
+				log.FromContext(ctx).Error(err, "unable to requeue NamespaceLabels after a reload")
+				continue
+			}
+			for _, nl := range list.Items {
+				queue.Add(reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&nl)})
+			}
+		}
+	}()
+	return nil
+}
+
+// namespaceMetadata returns an empty metadata-only Namespace. The controller
+// only ever needs the labels of a namespace, so it reads, watches and
+// patches them in this form and the cache never holds specs or status.
+func namespaceMetadata() *metav1.PartialObjectMetadata {
+	ns := &metav1.PartialObjectMetadata{}
+	ns.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+	return ns
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *NamespaceLabelReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	limits := r.Limits
+	if limits == (config.Limits{}) {
+		limits = config.DefaultLimits()
+	}
+	r.namespaceWrites = namespaceWriteLimiter(limits)
+	if r.reloaded == nil {
+		r.reloaded = make(chan struct{}, 1)
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &danaiov1alpha1.NamespaceLabel{},
+		sources.IndexField, sources.IndexValues); err != nil {
+		return err
+	}
+
+	// ConfigMaps and Secrets are watched as metadata only: any change to
+	// one requeues the NamespaceLabels reading it, which then fetch its data
+	// through the APIReader.
+	bldr := ctrl.NewControllerManagedBy(mgr).
 		For(&danaiov1alpha1.NamespaceLabel{}).
-		Named("namespacelabel").
-		Complete(r)
+		WatchesRawSource(source.Func(r.requeueOnReload)).
+		Watches(&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForSource(sources.KindConfigMap)),
+			builder.OnlyMetadata).
+		Watches(&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForSource(sources.KindSecret)),
+			builder.OnlyMetadata).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: limits.MaxConcurrentReconciles,
+			RateLimiter:             rateLimiter(limits),
+		})
+
+	// Objects created from NamespaceProfiles are owned by the NamespaceLabel
+	// whose label selected the profile. A changed profile may apply to any
+	// namespace, so it requeues every NamespaceLabel.
+	bldr = bldr.
+		Owns(&corev1.ResourceQuota{}, builder.WithPredicates(profileSpecChanged())).
+		Owns(&corev1.LimitRange{}, builder.WithPredicates(profileSpecChanged())).
+		Owns(&networkingv1.NetworkPolicy{}, builder.WithPredicates(profileSpecChanged())).
+		Watches(&danaiov1alpha1.NamespaceProfile{},
+			handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, _ client.Object) []reconcile.Request {
+				return r.requestsMatching(ctx)
+			}),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+
+	// Objects receiving propagated labels are watched as metadata only too.
+	for _, k := range propagatedKinds {
+		obj := &metav1.PartialObjectMetadata{}
+		obj.SetGroupVersionKind(k.gvk)
+		bldr = bldr.Watches(obj,
+			handler.EnqueueRequestsFromMapFunc(r.requestsForPropagation),
+			builder.WithPredicates(predicate.LabelChangedPredicate{}))
+	}
+
+	// The manager's Namespace informer only holds the namespaces the filter
+	// selects, but namespaces read through spec.labelsFrom need not be
+	// managed. They are watched through a cache of their own without the
+	// filter, which is only possible when the controller may watch every
+	// Namespace.
+	if len(r.WatchNamespaces) == 0 {
+		bldr = bldr.Watches(&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForNamespace),
+			builder.OnlyMetadata,
+			builder.WithPredicates(predicate.LabelChangedPredicate{}, namespacePredicate(r.Namespaces)))
+
+		refCache, err := cache.New(mgr.GetConfig(), cache.Options{
+			Scheme: mgr.GetScheme(),
+			Mapper: mgr.GetRESTMapper(),
+			ByObject: map[client.Object]cache.ByObject{
+				&corev1.Namespace{}: {Transform: cache.TransformStripManagedFields()},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("creating cache for referenced namespaces: %w", err)
+		}
+		if err := mgr.Add(refCache); err != nil {
+			return err
+		}
+		r.namespaceCaches = append(r.namespaceCaches, refCache)
+		bldr = bldr.WatchesRawSource(source.Kind[client.Object](refCache, namespaceMetadata(),
+			handler.EnqueueRequestsFromMapFunc(r.requestsForSource(sources.KindNamespace)),
+			predicate.LabelChangedPredicate{}))
+	}
+	for _, name := range r.WatchNamespaces {
+		byObject := NamespaceCache(r.Namespaces)
+		byObject.Field = fields.OneTermEqualSelector("metadata.name", name)
+		nsCache, err := cache.New(mgr.GetConfig(), cache.Options{
+			Scheme:   mgr.GetScheme(),
+			Mapper:   mgr.GetRESTMapper(),
+			ByObject: map[client.Object]cache.ByObject{&corev1.Namespace{}: byObject},
+		})
+		if err != nil {
+			return fmt.Errorf("creating cache for namespace %s: %w", name, err)
+		}
+		if err := mgr.Add(nsCache); err != nil {
+			return err
+		}
+		r.namespaceCaches = append(r.namespaceCaches, nsCache)
+		bldr = bldr.WatchesRawSource(source.Kind[client.Object](nsCache, namespaceMetadata(),
+			handler.EnqueueRequestsFromMapFunc(r.requestsForNamespace),
+			predicate.LabelChangedPredicate{}, namespacePredicate(r.Namespaces)))
+	}
+
+	return bldr.Named(ControllerName).Complete(r)
+}
+
+// NamespaceCaches returns the Namespace caches SetupWithManager created
+// besides the manager's, so readiness can wait for them to sync too.
+func (r *NamespaceLabelReconciler) NamespaceCaches() []cache.Cache {
+	return r.namespaceCaches
 }
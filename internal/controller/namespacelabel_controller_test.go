@@ -21,13 +21,20 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
 	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
 )
 
 var _ = Describe("NamespaceLabel Controller", func() {
@@ -38,11 +45,32 @@ var _ = Describe("NamespaceLabel Controller", func() {
 
 		typeNamespacedName := types.NamespacedName{
 			Name:      resourceName,
-			Namespace: "default", // TODO(user):Modify as needed
+			Namespace: "default",
 		}
 		namespacelabel := &danaiov1alpha1.NamespaceLabel{}
 
+		var controllerReconciler *NamespaceLabelReconciler
+
+		reconcileResource := func() {
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		namespaceLabels := func() map[string]string {
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "default"}, ns)).To(Succeed())
+			return ns.Labels
+		}
+
 		BeforeEach(func() {
+			controllerReconciler = &NamespaceLabelReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Policy: plan.DefaultPolicy(),
+			}
+
 			By("creating the custom resource for the Kind NamespaceLabel")
 			err := k8sClient.Get(ctx, typeNamespacedName, namespacelabel)
 			if err != nil && errors.IsNotFound(err) {
@@ -51,34 +79,279 @@ var _ = Describe("NamespaceLabel Controller", func() {
 						Name:      resourceName,
 						Namespace: "default",
 					},
-					// TODO(user): Specify other spec details if needed.
+					Spec: danaiov1alpha1.NamespaceLabelSpec{
+						Labels: map[string]string{
+							"team":                    "platform",
+							"node.kubernetes.io/role": "tenant",
+						},
+					},
 				}
 				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
 			}
 		})
 
 		AfterEach(func() {
-			// TODO(user): Cleanup logic after each test, like removing the resource instance.
 			resource := &danaiov1alpha1.NamespaceLabel{}
 			err := k8sClient.Get(ctx, typeNamespacedName, resource)
 			Expect(err).NotTo(HaveOccurred())
 
 			By("Cleanup the specific resource instance NamespaceLabel")
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			reconcileResource()
+
+			err = k8sClient.Get(ctx, typeNamespacedName, resource)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+			Expect(namespaceLabels()).NotTo(HaveKey("team"))
 		})
-		It("should successfully reconcile the resource", func() {
+
+		It("should apply the allowed labels to the namespace", func() {
 			By("Reconciling the created resource")
-			controllerReconciler := &NamespaceLabelReconciler{
-				Client: k8sClient,
-				Scheme: k8sClient.Scheme(),
+			reconcileResource()
+
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "platform"))
+			Expect(namespaceLabels()).NotTo(HaveKey("node.kubernetes.io/role"))
+
+			resource := &danaiov1alpha1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.AppliedLabels).To(Equal(map[string]string{"team": "platform"}))
+			Expect(resource.Status.RejectedLabels).To(ConsistOf(HaveField("Key", "node.kubernetes.io/role")))
+			Expect(meta.IsStatusConditionFalse(resource.Status.Conditions, danaiov1alpha1.ConditionReady)).To(BeTrue())
+		})
+
+		It("should restore labels changed outside the operator", func() {
+			reconcileResource()
+
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "default"}, ns)).To(Succeed())
+			ns.Labels["team"] = "someone-else"
+			Expect(k8sClient.Update(ctx, ns, client.FieldOwner("kubectl-edit"))).To(Succeed())
+
+			reconcileResource()
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "platform"))
+		})
+
+		It("should leave labels owned by another field manager to it", func() {
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "default"}, ns)).To(Succeed())
+			if ns.Labels == nil {
+				ns.Labels = map[string]string{}
 			}
+			ns.Labels["team"] = "gitops"
+			Expect(k8sClient.Update(ctx, ns, client.FieldOwner("argocd"))).To(Succeed())
 
-			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
-				NamespacedName: typeNamespacedName,
-			})
+			reconcileResource()
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "gitops"))
+
+			resource := &danaiov1alpha1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.RejectedLabels).To(ContainElement(SatisfyAll(
+				HaveField("Key", "team"),
+				HaveField("Reason", danaiov1alpha1.RejectedReasonForeignOwner))))
+			Expect(resource.Status.ForeignOwners).To(ContainElement(
+				danaiov1alpha1.ForeignOwner{Key: "team", Managers: []string{"argocd"}}))
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions,
+				danaiov1alpha1.ConditionForeignOwner)).To(BeTrue())
+
+			By("handing the label back")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "default"}, ns)).To(Succeed())
+			delete(ns.Labels, "team")
+			Expect(k8sClient.Update(ctx, ns, client.FieldOwner("argocd"))).To(Succeed())
+		})
+
+		It("should apply nothing when the quota is exceeded", func() {
+			controllerReconciler.Policy.Quota = plan.Quota{MaxLabelsPerObject: 1}
+			reconcileResource()
+
+			Expect(namespaceLabels()).NotTo(HaveKey("team"))
+
+			resource := &danaiov1alpha1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.AppliedLabels).To(BeEmpty())
+			Expect(resource.Status.RejectedLabels).To(BeEmpty())
+			ready := meta.FindStatusCondition(resource.Status.Conditions, danaiov1alpha1.ConditionReady)
+			Expect(ready).NotTo(BeNil())
+			Expect(ready.Reason).To(Equal("QuotaExceeded"))
+		})
+
+		It("should only report the plan in Plan mode", func() {
+			resource := &danaiov1alpha1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Mode = danaiov1alpha1.ModePlan
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			reconcileResource()
+			Expect(namespaceLabels()).NotTo(HaveKey("team"))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.Plan).NotTo(BeNil())
+			Expect(resource.Status.Plan.Add).To(ConsistOf(danaiov1alpha1.LabelChange{Key: "team", NewValue: "platform"}))
+			Expect(resource.Status.Plan.Rejected).To(ConsistOf(HaveField("Key", "node.kubernetes.io/role")))
+
+			By("switching back to Apply mode")
+			resource.Spec.Mode = danaiov1alpha1.ModeApply
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			reconcileResource()
+			Expect(namespaceLabels()).To(HaveKeyWithValue("team", "platform"))
+		})
+
+		It("should only report the plan in dry-run mode", func() {
+			controllerReconciler.DryRun = true
+			reconcileResource()
+
+			Expect(namespaceLabels()).NotTo(HaveKey("team"))
+
+			resource := &danaiov1alpha1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Finalizers).To(BeEmpty())
+			Expect(resource.Status.AppliedLabels).To(BeEmpty())
+			Expect(resource.Status.Plan).NotTo(BeNil())
+			Expect(resource.Status.Plan.Add).To(ConsistOf(danaiov1alpha1.LabelChange{Key: "team", NewValue: "platform"}))
+		})
+
+		It("should follow label values read from a ConfigMap", func() {
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "team-info", Namespace: "default"},
+				Data:       map[string]string{"owner": "alice"},
+			}
+			Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, configMap)).To(Succeed()) })
+
+			resource := &danaiov1alpha1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.LabelsFrom = []danaiov1alpha1.LabelFrom{{
+				Key: "owner",
+				ValueFrom: danaiov1alpha1.LabelValueSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "team-info"}, Key: "owner",
+				}},
+			}}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			reconcileResource()
+			Expect(namespaceLabels()).To(HaveKeyWithValue("owner", "alice"))
+
+			configMap.Data["owner"] = "bob"
+			Expect(k8sClient.Update(ctx, configMap)).To(Succeed())
+			reconcileResource()
+			Expect(namespaceLabels()).To(HaveKeyWithValue("owner", "bob"))
+		})
+
+		It("should propagate selected labels to Deployments and clean them up", func() {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Labels: map[string]string{"app": "web"}},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+						Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: "nginx"}}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, deployment)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, deployment)).To(Succeed()) })
+
+			resource := &danaiov1alpha1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Propagate = &danaiov1alpha1.Propagate{
+				Keys:         []string{"team"},
+				Kinds:        []danaiov1alpha1.PropagateKind{danaiov1alpha1.PropagateDeployment},
+				PodTemplates: true,
+			}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			reconcileResource()
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(deployment), deployment)).To(Succeed())
+			Expect(deployment.Labels).To(HaveKeyWithValue("team", "platform"))
+			Expect(deployment.Spec.Template.Labels).To(HaveKeyWithValue("team", "platform"))
+			Expect(deployment.Annotations).To(HaveKey(PropagatedAnnotation))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.PropagatedLabels).To(Equal(map[string]string{"team": "platform"}))
+
+			By("dropping spec.propagate")
+			resource.Spec.Propagate = nil
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			reconcileResource()
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(deployment), deployment)).To(Succeed())
+			Expect(deployment.Labels).To(Equal(map[string]string{"app": "web"}))
+			Expect(deployment.Spec.Template.Labels).To(Equal(map[string]string{"app": "web"}))
+			Expect(deployment.Annotations).NotTo(HaveKey(PropagatedAnnotation))
+		})
+
+		It("should create the objects of matching profiles and delete them when the label changes", func() {
+			profile := &danaiov1alpha1.NamespaceProfile{
+				ObjectMeta: metav1.ObjectMeta{Name: "team-platform"},
+				Spec: danaiov1alpha1.NamespaceProfileSpec{
+					Key:   "team",
+					Value: "platform",
+					ResourceQuotas: []danaiov1alpha1.ResourceQuotaTemplate{{
+						Name: "platform-quota",
+						Spec: corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{
+							corev1.ResourcePods: resource.MustParse("10"),
+						}},
+					}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, profile)).To(Succeed())
+			DeferCleanup(func() { Expect(k8sClient.Delete(ctx, profile)).To(Succeed()) })
+
+			reconcileResource()
+
+			quota := &corev1.ResourceQuota{}
+			quotaKey := types.NamespacedName{Namespace: "default", Name: "platform-quota"}
+			Expect(k8sClient.Get(ctx, quotaKey, quota)).To(Succeed())
+			Expect(quota.Labels).To(HaveKeyWithValue(ProfileLabel, "team-platform"))
+			Expect(quota.OwnerReferences).To(ConsistOf(HaveField("Name", resourceName)))
+
+			nl := &danaiov1alpha1.NamespaceLabel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, nl)).To(Succeed())
+			Expect(nl.Status.Profiles).To(Equal([]string{"team-platform"}))
+
+			By("changing the profile of an object other tools label too")
+			quota.Labels["owner"] = "finance"
+			quota.Annotations = map[string]string{"note": "reviewed"}
+			Expect(k8sClient.Update(ctx, quota)).To(Succeed())
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(profile), profile)).To(Succeed())
+			profile.Spec.ResourceQuotas[0].Spec.Hard[corev1.ResourcePods] = resource.MustParse("20")
+			Expect(k8sClient.Update(ctx, profile)).To(Succeed())
+			reconcileResource()
+
+			Expect(k8sClient.Get(ctx, quotaKey, quota)).To(Succeed())
+			Expect(quota.Spec.Hard.Pods().String()).To(Equal("20"))
+			Expect(quota.Labels).To(Equal(map[string]string{ProfileLabel: "team-platform", "owner": "finance"}))
+			Expect(quota.Annotations).To(Equal(map[string]string{"note": "reviewed"}))
+
+			By("changing the label value")
+			nl.Spec.Labels["team"] = "payments"
+			Expect(k8sClient.Update(ctx, nl)).To(Succeed())
+			reconcileResource()
+
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, quotaKey, quota))).To(BeTrue())
+		})
+
+		It("should refuse NamespaceLabels in namespaces that are not managed", func() {
+			expectRefused := func(message string) {
+				reconcileResource()
+				Expect(namespaceLabels()).NotTo(HaveKey("team"))
+
+				resource := &danaiov1alpha1.NamespaceLabel{}
+				Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+				cond := meta.FindStatusCondition(resource.Status.Conditions, danaiov1alpha1.ConditionReady)
+				Expect(cond).NotTo(BeNil())
+				Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+				Expect(cond.Reason).To(Equal("NamespaceExcluded"))
+				Expect(cond.Message).To(ContainSubstring(message))
+			}
+
+			By("excluding the namespace by name")
+			controllerReconciler.Namespaces = plan.NamespaceFilter{Exclude: []string{"def*"}}
+			expectRefused(`matches the excluded pattern "def*"`)
+
+			By("selecting other namespaces by label")
+			selector, err := labels.Parse("tenant=true")
 			Expect(err).NotTo(HaveOccurred())
-			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
-			// Example: If you expect a certain status condition after reconciliation, verify it here.
+			controllerReconciler.Namespaces = plan.NamespaceFilter{Selector: selector}
+			expectRefused(`does not match the namespace selector "tenant=true"`)
 		})
 	})
 })
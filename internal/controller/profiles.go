@@ -0,0 +1,210 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+// ProfileLabel names the NamespaceProfile an object in a namespace was
+// created from.
+const ProfileLabel = "dana.io.namespacelabel.com/profile"
+
+// profileLists are the list types of the objects profiles create.
+func profileLists() []client.ObjectList {
+	return []client.ObjectList{
+		&corev1.ResourceQuotaList{},
+		&corev1.LimitRangeList{},
+		&networkingv1.NetworkPolicyList{},
+	}
+}
+
+// applyProfiles creates the objects of every NamespaceProfile matching a
+// label in applied in the namespace of nl, owned by nl, and deletes the
+// objects nl created for profiles that no longer match. It returns the names
+// of the matching profiles. An object of the same name that nl does not own
+// is left alone and reported as an event.
+func (r *NamespaceLabelReconciler) applyProfiles(ctx context.Context, nl *danaiov1alpha1.NamespaceLabel,
+	applied map[string]string) ([]string, error) {
+	var profiles danaiov1alpha1.NamespaceProfileList
+	if err := r.List(ctx, &profiles); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	desired := map[string]client.Object{}
+	for i := range profiles.Items {
+		profile := &profiles.Items[i]
+		if value, ok := applied[profile.Spec.Key]; !ok || value != profile.Spec.Value {
+			continue
+		}
+		names = append(names, profile.Name)
+		for _, obj := range profileObjects(profile, nl.Namespace) {
+			key := objectKey(obj)
+			if other, taken := desired[key]; taken {
+				r.event(nl, corev1.EventTypeWarning, "ProfileConflict", fmt.Sprintf(
+					"NamespaceProfiles %s and %s both define %s", other.GetLabels()[ProfileLabel], profile.Name, key))
+				continue
+			}
+			desired[key] = obj
+		}
+	}
+	sort.Strings(names)
+
+	for _, list := range profileLists() {
+		if err := r.List(ctx, list, client.InNamespace(nl.Namespace), client.HasLabels{ProfileLabel}); err != nil {
+			return nil, err
+		}
+		if err := meta.EachListItem(list, func(item runtime.Object) error {
+			obj := item.(client.Object)
+			if _, keep := desired[objectKey(obj)]; keep || !metav1.IsControlledBy(obj, nl) {
+				return nil
+			}
+			return client.IgnoreNotFound(r.Delete(ctx, obj))
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range plan.SortedKeys(desired) {
+		want := desired[key]
+		if err := controllerutil.SetControllerReference(nl, want, r.Scheme); err != nil {
+			return nil, err
+		}
+		existing := want.DeepCopyObject().(client.Object)
+		err := r.Get(ctx, client.ObjectKeyFromObject(want), existing)
+		switch {
+		case apierrors.IsNotFound(err):
+			if err := r.Create(ctx, want); err != nil {
+				return nil, err
+			}
+			continue
+		case err != nil:
+			return nil, err
+		case !metav1.IsControlledBy(existing, nl):
+			r.event(nl, corev1.EventTypeWarning, "ProfileConflict", fmt.Sprintf(
+				"%s already exists and is not owned by this NamespaceLabel", key))
+			continue
+		}
+		if equality.Semantic.DeepEqual(profileSpec(existing), profileSpec(want)) &&
+			existing.GetLabels()[ProfileLabel] == want.GetLabels()[ProfileLabel] {
+			continue
+		}
+		if err := r.Update(ctx, withProfileSpec(existing, want)); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// profileObjects returns the objects profile creates in namespace.
+func profileObjects(profile *danaiov1alpha1.NamespaceProfile, namespace string) []client.Object {
+	objectMeta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{ProfileLabel: profile.Name},
+		}
+	}
+	var objs []client.Object
+	for _, t := range profile.Spec.ResourceQuotas {
+		objs = append(objs, &corev1.ResourceQuota{ObjectMeta: objectMeta(t.Name), Spec: *t.Spec.DeepCopy()})
+	}
+	for _, t := range profile.Spec.LimitRanges {
+		objs = append(objs, &corev1.LimitRange{ObjectMeta: objectMeta(t.Name), Spec: *t.Spec.DeepCopy()})
+	}
+	for _, t := range profile.Spec.NetworkPolicies {
+		objs = append(objs, &networkingv1.NetworkPolicy{ObjectMeta: objectMeta(t.Name), Spec: *t.Spec.DeepCopy()})
+	}
+	return objs
+}
+
+// profileSpec returns the part of a profile object the profile decides.
+func profileSpec(obj client.Object) any {
+	switch o := obj.(type) {
+	case *corev1.ResourceQuota:
+		return o.Spec
+	case *corev1.LimitRange:
+		return o.Spec
+	case *networkingv1.NetworkPolicy:
+		return o.Spec
+	}
+	return nil
+}
+
+// withProfileSpec returns a copy of existing with the spec and profile label
+// of want. Other labels and annotations, set by other tools, are kept.
+func withProfileSpec(existing, want client.Object) client.Object {
+	updated := existing.DeepCopyObject().(client.Object)
+	switch o := updated.(type) {
+	case *corev1.ResourceQuota:
+		o.Spec = want.(*corev1.ResourceQuota).Spec
+	case *corev1.LimitRange:
+		o.Spec = want.(*corev1.LimitRange).Spec
+	case *networkingv1.NetworkPolicy:
+		o.Spec = want.(*networkingv1.NetworkPolicy).Spec
+	}
+	labels := updated.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[ProfileLabel] = want.GetLabels()[ProfileLabel]
+	updated.SetLabels(labels)
+	return updated
+}
+
+// profileSpecChanged passes updates that change what a profile decides, so
+// the usage a ResourceQuota reports in its status does not requeue.
+func profileSpecChanged() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return !equality.Semantic.DeepEqual(profileSpec(e.ObjectOld), profileSpec(e.ObjectNew)) ||
+				e.ObjectOld.GetLabels()[ProfileLabel] != e.ObjectNew.GetLabels()[ProfileLabel]
+		},
+	}
+}
+
+// objectKey identifies a profile object within its namespace.
+func objectKey(obj client.Object) string {
+	var kind string
+	switch obj.(type) {
+	case *corev1.ResourceQuota:
+		kind = "ResourceQuota"
+	case *corev1.LimitRange:
+		kind = "LimitRange"
+	case *networkingv1.NetworkPolicy:
+		kind = "NetworkPolicy"
+	}
+	return kind + " " + obj.GetName()
+}
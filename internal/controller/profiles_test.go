@@ -0,0 +1,81 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+)
+
+var _ = Describe("Profiles", func() {
+	It("records the label change and reports a profile that cannot be applied", func() {
+		r, sink := newFakeReconciler(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if _, ok := obj.(*corev1.ResourceQuota); ok {
+					return apierrors.NewForbidden(schema.GroupResource{Resource: "resourcequotas"},
+						obj.GetName(), errors.New("denied"))
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		},
+			&danaiov1alpha1.NamespaceLabel{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "labels"},
+				Spec:       danaiov1alpha1.NamespaceLabelSpec{Labels: map[string]string{"tier": "gold"}},
+			},
+			&danaiov1alpha1.NamespaceProfile{
+				ObjectMeta: metav1.ObjectMeta{Name: "gold"},
+				Spec: danaiov1alpha1.NamespaceProfileSpec{
+					Key:   "tier",
+					Value: "gold",
+					ResourceQuotas: []danaiov1alpha1.ResourceQuotaTemplate{{
+						Name: "gold-quota",
+						Spec: corev1.ResourceQuotaSpec{Hard: corev1.ResourceList{
+							corev1.ResourcePods: resource.MustParse("10"),
+						}},
+					}},
+				},
+			},
+		)
+
+		key := client.ObjectKey{Namespace: "team-a", Name: "labels"}
+		_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key})
+		Expect(apierrors.IsForbidden(err)).To(BeTrue())
+		Expect(sink.keys()).To(Equal([]string{"tier"}))
+
+		nl := &danaiov1alpha1.NamespaceLabel{}
+		Expect(r.Get(context.Background(), key, nl)).To(Succeed())
+		Expect(nl.Status.AppliedLabels).To(Equal(map[string]string{"tier": "gold"}))
+		cond := meta.FindStatusCondition(nl.Status.Conditions, danaiov1alpha1.ConditionReady)
+		Expect(cond).NotTo(BeNil())
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal("ProfilesFailed"))
+	})
+})
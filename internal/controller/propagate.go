@@ -0,0 +1,213 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+// PropagatedAnnotation records, on an object in the namespace, the labels
+// NamespaceLabels copied to it and to its pod template, so only those are
+// ever changed or removed.
+const PropagatedAnnotation = "dana.io.namespacelabel.com/propagated"
+
+// propagatedKinds are the kinds spec.propagate may select, in a fixed order.
+var propagatedKinds = []struct {
+	kind        danaiov1alpha1.PropagateKind
+	gvk         schema.GroupVersionKind
+	podTemplate bool
+}{
+	{danaiov1alpha1.PropagateDeployment, appsv1.SchemeGroupVersion.WithKind("Deployment"), true},
+	{danaiov1alpha1.PropagateStatefulSet, appsv1.SchemeGroupVersion.WithKind("StatefulSet"), true},
+	{danaiov1alpha1.PropagatePersistentVolumeClaim, corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"), false},
+}
+
+// propagation is the value of PropagatedAnnotation.
+type propagation struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	PodTemplate map[string]string `json:"podTemplate,omitempty"`
+}
+
+// propagate copies the keys of nl.Spec.Propagate found in applied to the
+// selected objects in the namespace of nl, and removes the labels it copied
+// before that are no longer wanted. It returns the labels now propagated.
+// When finalizing, every label nl propagated is removed.
+//
+// Objects are read from the metadata-only cache. A pod template is never
+// read: its labels are assumed to be the ones last written to it.
+func (r *NamespaceLabelReconciler) propagate(ctx context.Context, nl *danaiov1alpha1.NamespaceLabel,
+	applied map[string]string, finalizing bool) (map[string]string, error) {
+	spec := nl.Spec.Propagate
+	if spec == nil && len(nl.Status.PropagatedLabels) == 0 {
+		return nil, nil
+	}
+
+	// nl is responsible for the keys it propagates now and the ones it did
+	// before; any other key in the annotation belongs to another
+	// NamespaceLabel.
+	desired := map[string]string{}
+	responsible := map[string]bool{}
+	for key := range nl.Status.PropagatedLabels {
+		responsible[key] = true
+	}
+	kinds := map[danaiov1alpha1.PropagateKind]bool{}
+	if spec != nil {
+		for _, key := range spec.Keys {
+			responsible[key] = true
+			if value, ok := applied[key]; ok && !finalizing {
+				desired[key] = value
+			}
+		}
+		for _, kind := range spec.Kinds {
+			kinds[kind] = true
+		}
+	}
+
+	for _, k := range propagatedKinds {
+		want := map[string]string{}
+		if kinds[k.kind] {
+			want = desired
+		}
+		wantTemplate := map[string]string{}
+		if k.podTemplate && spec != nil && spec.PodTemplates {
+			wantTemplate = want
+		}
+
+		list := &metav1.PartialObjectMetadataList{}
+		list.SetGroupVersionKind(k.gvk.GroupVersion().WithKind(k.gvk.Kind + "List"))
+		if err := r.List(ctx, list, client.InNamespace(nl.Namespace)); err != nil {
+			return nil, fmt.Errorf("listing %ss: %w", k.kind, err)
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			obj.SetGroupVersionKind(k.gvk)
+			err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+				err := r.propagateTo(ctx, obj, responsible, want, wantTemplate)
+				if apierrors.IsConflict(err) {
+					// The cached copy is stale: read the object again for the
+					// next attempt. One deleted meanwhile needs nothing.
+					if err := r.sourceReader().Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+						return client.IgnoreNotFound(err)
+					}
+				}
+				return err
+			})
+			if err != nil {
+				return nil, fmt.Errorf("propagating labels to %s %s: %w", k.kind, obj.Name, err)
+			}
+		}
+	}
+	if len(desired) == 0 {
+		return nil, nil
+	}
+	return desired, nil
+}
+
+// propagateTo brings the labels of obj and of its pod template in line with
+// want and wantTemplate for the keys in responsible, in a single patch. The
+// patch carries the resourceVersion of obj, so a concurrent change to the
+// annotation causes a conflict rather than a lost update.
+func (r *NamespaceLabelReconciler) propagateTo(ctx context.Context, obj *metav1.PartialObjectMetadata,
+	responsible map[string]bool, want, wantTemplate map[string]string) error {
+	var previous propagation
+	if value, ok := obj.Annotations[PropagatedAnnotation]; ok {
+		// A corrupt annotation only means nothing is known to be ours.
+		_ = json.Unmarshal([]byte(value), &previous)
+	}
+	owned, others := splitKeys(previous.Labels, responsible)
+	ownedTemplate, otherTemplate := splitKeys(previous.PodTemplate, responsible)
+
+	lp := plan.Compute(plan.Input{Current: obj.Labels, Desired: want, Owned: owned}, plan.Policy{})
+	tp := plan.Compute(plan.Input{Current: ownedTemplate, Desired: wantTemplate, Owned: ownedTemplate}, plan.Policy{})
+
+	next := propagation{Labels: others, PodTemplate: otherTemplate}
+	maps.Copy(next.Labels, lp.Applied)
+	maps.Copy(next.PodTemplate, tp.Applied)
+	if lp.Empty() && tp.Empty() && maps.Equal(next.Labels, previous.Labels) &&
+		maps.Equal(next.PodTemplate, previous.PodTemplate) {
+		return nil
+	}
+
+	annotation := any(nil)
+	if len(next.Labels) > 0 || len(next.PodTemplate) > 0 {
+		data, err := json.Marshal(next)
+		if err != nil {
+			return err
+		}
+		annotation = string(data)
+	}
+	patch := map[string]any{
+		"metadata": map[string]any{
+			"resourceVersion": obj.ResourceVersion,
+			"labels":          labelPatch(lp),
+			"annotations":     map[string]any{PropagatedAnnotation: annotation},
+		},
+	}
+	if !tp.Empty() {
+		patch["spec"] = map[string]any{
+			"template": map[string]any{"metadata": map[string]any{"labels": labelPatch(tp)}},
+		}
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	return r.Patch(ctx, obj, client.RawPatch(types.MergePatchType, data))
+}
+
+// splitKeys splits labels into the keys in responsible and the others.
+func splitKeys(labels map[string]string, responsible map[string]bool) (mine, others map[string]string) {
+	mine, others = map[string]string{}, map[string]string{}
+	for key, value := range labels {
+		if responsible[key] {
+			mine[key] = value
+		} else {
+			others[key] = value
+		}
+	}
+	return mine, others
+}
+
+// labelPatch returns the merge patch of a label map making the changes of p.
+func labelPatch(p plan.Plan) map[string]any {
+	patch := map[string]any{}
+	for _, c := range p.Add {
+		patch[c.Key] = c.New
+	}
+	for _, c := range p.Update {
+		patch[c.Key] = c.New
+	}
+	for _, c := range p.Remove {
+		patch[c.Key] = nil
+	}
+	return patch
+}
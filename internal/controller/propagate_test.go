@@ -0,0 +1,158 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/audit"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+// recordingSink keeps the audit events it receives.
+type recordingSink struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+func (s *recordingSink) Record(events ...audit.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, events...)
+	return nil
+}
+
+func (s *recordingSink) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0, len(s.events))
+	for _, e := range s.events {
+		keys = append(keys, e.Key)
+	}
+	return keys
+}
+
+// newFakeReconciler returns a reconciler for a fake cluster holding the
+// namespace team-a and objs, with every write passed through funcs.
+func newFakeReconciler(funcs interceptor.Funcs, objs ...client.Object) (*NamespaceLabelReconciler, *recordingSink) {
+	scheme := runtime.NewScheme()
+	Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	Expect(danaiov1alpha1.AddToScheme(scheme)).To(Succeed())
+
+	objs = append(objs, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}})
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&danaiov1alpha1.NamespaceLabel{}).
+		WithInterceptorFuncs(funcs).
+		Build()
+	sink := &recordingSink{}
+	return &NamespaceLabelReconciler{Client: c, Scheme: scheme, Policy: plan.DefaultPolicy(), Audit: sink}, sink
+}
+
+// isKind reports whether obj, typed or metadata-only, is of the given kind.
+func isKind(obj client.Object, gvk schema.GroupVersionKind) bool {
+	if _, ok := obj.(*appsv1.Deployment); ok {
+		return gvk.Kind == "Deployment"
+	}
+	return obj.GetObjectKind().GroupVersionKind() == gvk
+}
+
+var _ = Describe("Propagation", func() {
+	deploymentGVK := appsv1.SchemeGroupVersion.WithKind("Deployment")
+	request := reconcile.Request{NamespacedName: client.ObjectKey{Namespace: "team-a", Name: "labels"}}
+
+	var objs []client.Object
+	BeforeEach(func() {
+		objs = []client.Object{
+			&danaiov1alpha1.NamespaceLabel{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "labels"},
+				Spec: danaiov1alpha1.NamespaceLabelSpec{
+					Labels: map[string]string{"team": "a"},
+					Propagate: &danaiov1alpha1.Propagate{
+						Keys:  []string{"team"},
+						Kinds: []danaiov1alpha1.PropagateKind{danaiov1alpha1.PropagateDeployment},
+					},
+				},
+			},
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "web"}},
+		}
+	})
+
+	It("retries a propagation patch that conflicts", func() {
+		conflicts := 2
+		r, _ := newFakeReconciler(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch,
+				opts ...client.PatchOption) error {
+				if isKind(obj, deploymentGVK) && conflicts > 0 {
+					conflicts--
+					return apierrors.NewConflict(schema.GroupResource{Group: "apps", Resource: "deployments"},
+						obj.GetName(), errors.New("the object has been modified"))
+				}
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		}, objs...)
+
+		_, err := r.Reconcile(context.Background(), request)
+		Expect(err).NotTo(HaveOccurred())
+
+		deployment := &appsv1.Deployment{}
+		Expect(r.Get(context.Background(), client.ObjectKey{Namespace: "team-a", Name: "web"}, deployment)).To(Succeed())
+		Expect(deployment.Labels).To(HaveKeyWithValue("team", "a"))
+		Expect(conflicts).To(BeZero())
+	})
+
+	It("records a namespace change even when propagation fails", func() {
+		r, sink := newFakeReconciler(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch,
+				opts ...client.PatchOption) error {
+				if isKind(obj, deploymentGVK) {
+					return apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "deployments"},
+						obj.GetName(), errors.New("denied"))
+				}
+				return c.Patch(ctx, obj, patch, opts...)
+			},
+		}, objs...)
+
+		for range 2 {
+			_, err := r.Reconcile(context.Background(), request)
+			Expect(apierrors.IsForbidden(errors.Unwrap(err))).To(BeTrue())
+		}
+
+		ns := &corev1.Namespace{}
+		Expect(r.Get(context.Background(), client.ObjectKey{Name: "team-a"}, ns)).To(Succeed())
+		Expect(ns.Labels).To(HaveKeyWithValue("team", "a"))
+		Expect(sink.keys()).To(Equal([]string{"team"}))
+	})
+})
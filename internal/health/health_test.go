@@ -0,0 +1,131 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ = Describe("Progress", func() {
+	var (
+		progress *Progress
+		now      time.Time
+		queued   int
+		check    func() error
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		progress = &Progress{now: func() time.Time { return now }}
+		queued = 0
+		stalled := progress.Stalled(time.Minute, func() int { return queued })
+		check = func() error { return stalled(nil) }
+	})
+
+	It("stays healthy while idle", func() {
+		now = now.Add(time.Hour)
+		Expect(check()).To(Succeed())
+	})
+
+	It("fails when queued work makes no progress within the window", func() {
+		queued = 3
+		Expect(check()).To(Succeed())
+
+		now = now.Add(2 * time.Minute)
+		Expect(check()).To(MatchError(ContainSubstring("no successful reconcile in 2m0s with 3 requests pending")))
+	})
+
+	It("fails when a reconcile hangs", func() {
+		progress.Started()
+		now = now.Add(2 * time.Minute)
+		Expect(check()).To(HaveOccurred())
+
+		progress.Finished(nil)
+		Expect(check()).To(Succeed())
+	})
+
+	It("does not count failed reconciles as progress", func() {
+		queued = 1
+		progress.Started()
+		now = now.Add(30 * time.Second)
+		progress.Finished(errors.New("conflict"))
+		progress.Started()
+		now = now.Add(time.Minute)
+		Expect(check()).To(HaveOccurred())
+	})
+
+	It("starts a new window when work arrives after an idle stretch", func() {
+		progress.Started()
+		progress.Finished(nil)
+		Expect(check()).To(Succeed())
+
+		now = now.Add(time.Hour)
+		progress.Started()
+		Expect(check()).To(Succeed())
+	})
+
+	It("is disabled by a zero window", func() {
+		progress.Started()
+		now = now.Add(time.Hour)
+		Expect(progress.Stalled(0, func() int { return 1 })(nil)).To(Succeed())
+	})
+})
+
+var _ = Describe("QueueDepth", func() {
+	It("reads the depth of the named controller's workqueue", func() {
+		registry := prometheus.NewRegistry()
+		depth := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Subsystem: "workqueue",
+			Name:      "depth",
+		}, []string{"name", "controller"})
+		registry.MustRegister(depth)
+		depth.WithLabelValues("other", "other").Set(7)
+		depth.WithLabelValues("namespacelabel", "namespacelabel").Set(2)
+
+		Expect(QueueDepth(registry, "namespacelabel")()).To(Equal(2))
+		Expect(QueueDepth(registry, "missing")()).To(Equal(0))
+	})
+})
+
+var _ = Describe("WebhookServing", func() {
+	It("succeeds against a server with a valid certificate", func() {
+		server := httptest.NewTLSServer(http.NotFoundHandler())
+		defer server.Close()
+
+		req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+		Expect(WebhookServing(strings.TrimPrefix(server.URL, "https://"))(req)).To(Succeed())
+	})
+
+	It("fails when nothing is serving", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		addr := listener.Addr().String()
+		Expect(listener.Close()).To(Succeed())
+
+		req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+		Expect(WebhookServing(addr)(req)).To(MatchError(ContainSubstring("webhook server is not serving")))
+	})
+})
@@ -0,0 +1,162 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics holds the Prometheus collectors describing label
+// reconciliation. They are registered on the controller-runtime registry and
+// served from the manager's metrics endpoint.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+)
+
+const subsystem = "namespacelabel"
+
+var (
+	// LabelsApplied counts labels added to or updated on a namespace.
+	LabelsApplied = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "labels_applied_total",
+		Help:      "Number of labels added to or updated on a namespace.",
+	}, []string{"namespace"})
+
+	// LabelsRemoved counts labels removed from a namespace.
+	LabelsRemoved = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "labels_removed_total",
+		Help:      "Number of labels removed from a namespace.",
+	}, []string{"namespace"})
+
+	// LabelsRejected counts requested labels that were not applied, by reason.
+	LabelsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "labels_rejected_total",
+		Help:      "Number of requested labels that were not applied, by reason.",
+	}, []string{"namespace", "reason"})
+
+	// Conflicts counts requested keys already owned by another NamespaceLabel.
+	Conflicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "conflicts_total",
+		Help:      "Number of requested labels already owned by another NamespaceLabel.",
+	}, []string{"namespace"})
+
+	// DriftCorrections counts owned labels restored after an outside change.
+	DriftCorrections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "drift_corrections_total",
+		Help:      "Number of owned labels restored after being changed outside the operator.",
+	}, []string{"namespace"})
+
+	// PolicyViolations counts requested labels refused by the label policy.
+	PolicyViolations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: subsystem,
+		Name:      "policy_violations_total",
+		Help:      "Number of requested labels refused by the label policy.",
+	}, []string{"namespace"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		LabelsApplied,
+		LabelsRemoved,
+		LabelsRejected,
+		Conflicts,
+		DriftCorrections,
+		PolicyViolations,
+	)
+}
+
+// listTimeout bounds how long a scrape waits on the cache.
+const listTimeout = 5 * time.Second
+
+var objectsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName("", subsystem, "objects"),
+	"Number of NamespaceLabel objects by Ready condition status.",
+	[]string{"ready"}, nil,
+)
+
+var deprecatedDesc = prometheus.NewDesc(
+	prometheus.BuildFQName("", subsystem, "deprecated_key_objects"),
+	"Number of NamespaceLabel objects still requesting a deprecated label key.",
+	[]string{"key"}, nil,
+)
+
+// ReadyCollector reports the number of NamespaceLabel objects by the status
+// of their Ready condition and by the deprecated keys they request. It reads
+// from the manager cache at scrape time so deleted objects never linger in
+// the gauges.
+type ReadyCollector struct {
+	reader client.Reader
+}
+
+// NewReadyCollector returns a ReadyCollector listing objects through reader.
+func NewReadyCollector(reader client.Reader) *ReadyCollector {
+	return &ReadyCollector{reader: reader}
+}
+
+// Describe implements prometheus.Collector.
+func (c *ReadyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- objectsDesc
+	ch <- deprecatedDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *ReadyCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), listTimeout)
+	defer cancel()
+
+	var list danaiov1alpha1.NamespaceLabelList
+	if err := c.reader.List(ctx, &list); err != nil {
+		// The cache is not synced yet; report nothing rather than zeros.
+		return
+	}
+
+	counts := map[metav1.ConditionStatus]float64{
+		metav1.ConditionTrue:    0,
+		metav1.ConditionFalse:   0,
+		metav1.ConditionUnknown: 0,
+	}
+	for i := range list.Items {
+		status := metav1.ConditionUnknown
+		if cond := meta.FindStatusCondition(list.Items[i].Status.Conditions, danaiov1alpha1.ConditionReady); cond != nil {
+			status = cond.Status
+		}
+		counts[status]++
+	}
+	for status, n := range counts {
+		ch <- prometheus.MustNewConstMetric(objectsDesc, prometheus.GaugeValue, n, string(status))
+	}
+
+	deprecated := map[string]float64{}
+	for i := range list.Items {
+		for _, key := range list.Items[i].Status.DeprecatedKeys {
+			deprecated[key]++
+		}
+	}
+	for key, n := range deprecated {
+		ch <- prometheus.MustNewConstMetric(deprecatedDesc, prometheus.GaugeValue, n, key)
+	}
+}
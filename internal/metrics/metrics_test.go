@@ -0,0 +1,72 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+)
+
+var _ = Describe("ReadyCollector", func() {
+	It("counts NamespaceLabels by Ready status and deprecated key", func() {
+		scheme := runtime.NewScheme()
+		Expect(danaiov1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		object := func(name string, ready metav1.ConditionStatus, deprecated ...string) *danaiov1alpha1.NamespaceLabel {
+			nl := &danaiov1alpha1.NamespaceLabel{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name},
+				Status:     danaiov1alpha1.NamespaceLabelStatus{DeprecatedKeys: deprecated},
+			}
+			if ready != "" {
+				nl.Status.Conditions = []metav1.Condition{{Type: danaiov1alpha1.ConditionReady, Status: ready}}
+			}
+			return nl
+		}
+		reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			object("a", metav1.ConditionTrue, "team"),
+			object("b", metav1.ConditionTrue),
+			object("c", metav1.ConditionFalse, "team", "cost"),
+			object("d", ""),
+		).Build()
+
+		Expect(testutil.CollectAndCompare(NewReadyCollector(reader), strings.NewReader(`
+# HELP namespacelabel_deprecated_key_objects Number of NamespaceLabel objects still requesting a deprecated label key.
+# TYPE namespacelabel_deprecated_key_objects gauge
+namespacelabel_deprecated_key_objects{key="cost"} 1
+namespacelabel_deprecated_key_objects{key="team"} 2
+# HELP namespacelabel_objects Number of NamespaceLabel objects by Ready condition status.
+# TYPE namespacelabel_objects gauge
+namespacelabel_objects{ready="False"} 1
+namespacelabel_objects{ready="True"} 2
+namespacelabel_objects{ready="Unknown"} 1
+`))).To(Succeed())
+	})
+
+	It("reports nothing while the objects cannot be listed", func() {
+		reader := fake.NewClientBuilder().WithScheme(runtime.NewScheme()).Build()
+
+		Expect(testutil.CollectAndCount(NewReadyCollector(reader))).To(BeZero())
+	})
+})
@@ -0,0 +1,89 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plan
+
+import (
+	"encoding/json"
+	"path"
+	"slices"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FieldManager is the field manager the operator writes namespace labels as.
+const FieldManager = "namespacelabel"
+
+// DefaultOverrideFieldManagers are the field managers whose changes to the
+// labels the operator owns are set back rather than yielded to: hand edits
+// with kubectl, and "manager", which the operator wrote as before it set
+// its own field manager.
+var DefaultOverrideFieldManagers = []string{"kubectl", "kubectl-*", "manager"}
+
+// ManagedLabelKeys returns the label keys entry owns.
+func ManagedLabelKeys(entry metav1.ManagedFieldsEntry) []string {
+	if entry.Subresource != "" || entry.FieldsV1 == nil {
+		return nil
+	}
+	var fields struct {
+		Metadata struct {
+			Labels map[string]struct{} `json:"f:labels"`
+		} `json:"f:metadata"`
+	}
+	if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+		return nil
+	}
+	keys := make([]string, 0, len(fields.Metadata.Labels))
+	for field := range fields.Metadata.Labels {
+		if len(field) > 2 && field[:2] == "f:" {
+			keys = append(keys, field[2:])
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ForeignOwners returns, for each label key of obj, the field managers other
+// than FieldManager that own it according to its managedFields, sorted.
+// Managers matching OverrideFieldManagers are left out.
+func (p Policy) ForeignOwners(obj metav1.Object) map[string][]string {
+	owners := map[string][]string{}
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager == FieldManager || p.overrides(entry.Manager) {
+			continue
+		}
+		for _, key := range ManagedLabelKeys(entry) {
+			if !slices.Contains(owners[key], entry.Manager) {
+				owners[key] = append(owners[key], entry.Manager)
+			}
+		}
+	}
+	for _, managers := range owners {
+		sort.Strings(managers)
+	}
+	return owners
+}
+
+// overrides reports whether the operator sets back changes made by manager.
+func (p Policy) overrides(manager string) bool {
+	for _, pattern := range p.OverrideFieldManagers {
+		if ok, _ := path.Match(pattern, manager); ok {
+			return true
+		}
+	}
+	return false
+}
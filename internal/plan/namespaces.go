@@ -0,0 +1,59 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plan
+
+import (
+	"path"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// DefaultExcludedNamespaces are the name patterns of the platform namespaces
+// the operator leaves alone unless configured otherwise.
+var DefaultExcludedNamespaces = []string{"kube-*", "openshift-*"}
+
+// NamespaceFilter selects the namespaces whose NamespaceLabels are managed.
+type NamespaceFilter struct {
+	// Selector must match the labels of a managed namespace. Nil matches
+	// every namespace.
+	Selector labels.Selector
+	// Exclude are path.Match patterns of namespace names that are never
+	// managed.
+	Exclude []string
+}
+
+// ExcludedBy returns the Exclude pattern matching the namespace called name.
+func (f NamespaceFilter) ExcludedBy(name string) (string, bool) {
+	for _, pattern := range f.Exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// Selects reports whether a namespace with the given labels matches Selector.
+func (f NamespaceFilter) Selects(nsLabels map[string]string) bool {
+	return f.Selector == nil || f.Selector.Matches(labels.Set(nsLabels))
+}
+
+// Manages reports whether the NamespaceLabels of a namespace with the given
+// name and labels are managed.
+func (f NamespaceFilter) Manages(name string, nsLabels map[string]string) bool {
+	_, excluded := f.ExcludedBy(name)
+	return !excluded && f.Selects(nsLabels)
+}
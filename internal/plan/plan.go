@@ -0,0 +1,257 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package plan computes the label changes a NamespaceLabel requires on its
+// namespace. It is free of any client so the same logic can back the
+// controller and offline tooling.
+package plan
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+)
+
+// Change is a single label mutation on a namespace.
+type Change struct {
+	Key string
+	// Old is the value on the namespace before the change, empty for adds.
+	Old string
+	// New is the value after the change, empty for removals.
+	New string
+}
+
+// Input is everything Compute needs to know about a NamespaceLabel and its
+// namespace.
+type Input struct {
+	// Current are the labels presently on the namespace.
+	Current map[string]string
+	// Desired are the labels requested by the NamespaceLabel spec.
+	Desired map[string]string
+	// From are the values read for spec.labelsFrom. They take precedence
+	// over Desired.
+	From map[string]string
+	// Unresolved are the spec.labelsFrom keys whose source could not be
+	// read. An owned label keeps its value until the source is back.
+	Unresolved []danaiov1alpha1.RejectedLabel
+	// ForeignOwners maps label keys to the field managers other than the
+	// operator that own them on the namespace. A requested key they own
+	// with a different value is left to them.
+	ForeignOwners map[string][]string
+	// PodSecurity are the labels translated from spec.podSecurity. They take
+	// precedence over Desired and From, and are checked with
+	// Policy.CheckPodSecurity instead of Policy.Check.
+	PodSecurity map[string]string
+	// Owned are the labels the NamespaceLabel applied on a previous pass.
+	Owned map[string]string
+	// Claimed maps keys owned by other NamespaceLabels in the same namespace
+	// to the name of the owning object.
+	Claimed map[string]string
+}
+
+// Plan is the set of changes needed to bring a namespace in line with a
+// NamespaceLabel.
+type Plan struct {
+	Add    []Change
+	Update []Change
+	Remove []Change
+
+	// Rejected are the requested labels that will not be applied.
+	Rejected []danaiov1alpha1.RejectedLabel
+	// Drifted are the owned keys whose value on the namespace was changed
+	// outside the operator and will be restored.
+	Drifted []string
+	// Deprecated are the requested keys that policy renames.
+	Deprecated []string
+	// ForeignOwners are the requested keys other field managers own too.
+	ForeignOwners []danaiov1alpha1.ForeignOwner
+	// QuotaExceeded says which policy quota the requested labels break. Such
+	// a plan carries no changes and no per-key rejections.
+	QuotaExceeded string
+	// Applied are the labels the NamespaceLabel owns once the plan executes.
+	Applied map[string]string
+}
+
+// ClaimedKeys returns the keys owned by the NamespaceLabels in siblings other
+// than the one named name, mapped to the name of their owner.
+func ClaimedKeys(name string, siblings []danaiov1alpha1.NamespaceLabel) map[string]string {
+	claimed := map[string]string{}
+	for i := range siblings {
+		other := &siblings[i]
+		if other.Name == name {
+			continue
+		}
+		for key := range other.Status.AppliedLabels {
+			claimed[key] = other.Name
+		}
+	}
+	return claimed
+}
+
+// Resolved are the values read for a NamespaceLabel's spec.labelsFrom.
+type Resolved struct {
+	// Values maps keys to the value read from their source.
+	Values map[string]string
+	// Rejected are the keys whose source is missing or not allowed.
+	Rejected []danaiov1alpha1.RejectedLabel
+}
+
+// ForNamespaceLabel returns the plan for nl given its namespace ns, every
+// NamespaceLabel in that namespace and the values read for its
+// spec.labelsFrom. The label ownership recorded in the managedFields of ns
+// decides which keys are left to other field managers.
+func ForNamespaceLabel(nl *danaiov1alpha1.NamespaceLabel, ns metav1.Object,
+	siblings []danaiov1alpha1.NamespaceLabel, resolved Resolved, policy Policy) Plan {
+	return Compute(Input{
+		Current:       ns.GetLabels(),
+		ForeignOwners: policy.ForeignOwners(ns),
+		Desired:       nl.Spec.Labels,
+		From:          resolved.Values,
+		Unresolved:    resolved.Rejected,
+		PodSecurity:   PodSecurityLabels(nl.Spec.PodSecurity),
+		Owned:         nl.Status.AppliedLabels,
+		Claimed:       ClaimedKeys(nl.Name, siblings),
+	}, policy)
+}
+
+// Compute returns the plan that reconciles in.Current with in.Desired under
+// policy.
+func Compute(in Input, policy Policy) Plan {
+	p := Plan{Applied: map[string]string{}}
+
+	desired := maps.Clone(in.Desired)
+	if desired == nil {
+		desired = map[string]string{}
+	}
+	maps.Copy(desired, in.From)
+	unresolved := map[string]bool{}
+	for _, rejected := range in.Unresolved {
+		unresolved[rejected.Key] = true
+		delete(desired, rejected.Key)
+	}
+	p.Rejected = append(p.Rejected, in.Unresolved...)
+	requested := len(desired) + len(unresolved) + len(in.PodSecurity)
+	p.Deprecated = policy.rename(desired)
+	maps.Copy(desired, in.PodSecurity)
+	for _, key := range SortedKeys(desired) {
+		value := desired[key]
+		check := policy.Check
+		if _, ok := in.PodSecurity[key]; ok {
+			check = policy.CheckPodSecurity
+		}
+		if rejected := check(key, value); rejected != nil {
+			p.Rejected = append(p.Rejected, *rejected)
+			continue
+		}
+		if owner, claimed := in.Claimed[key]; claimed {
+			p.Rejected = append(p.Rejected, danaiov1alpha1.RejectedLabel{
+				Key:     key,
+				Reason:  danaiov1alpha1.RejectedReasonConflict,
+				Message: fmt.Sprintf("key is owned by NamespaceLabel %q", owner),
+			})
+			continue
+		}
+
+		current, exists := in.Current[key]
+		if managers := in.ForeignOwners[key]; len(managers) > 0 {
+			p.ForeignOwners = append(p.ForeignOwners, danaiov1alpha1.ForeignOwner{Key: key, Managers: managers})
+			if exists && current != value {
+				p.Rejected = append(p.Rejected, danaiov1alpha1.RejectedLabel{
+					Key:    key,
+					Reason: danaiov1alpha1.RejectedReasonForeignOwner,
+					Message: fmt.Sprintf("field manager %s set the key to %q; leaving it to them",
+						strings.Join(managers, ", "), current),
+				})
+				continue
+			}
+		}
+
+		p.Applied[key] = value
+		switch {
+		case !exists:
+			p.Add = append(p.Add, Change{Key: key, New: value})
+		case current != value:
+			p.Update = append(p.Update, Change{Key: key, Old: current, New: value})
+		}
+		if owned, ok := in.Owned[key]; ok && owned == value && current != value {
+			p.Drifted = append(p.Drifted, key)
+		}
+	}
+
+	for _, key := range SortedKeys(in.Owned) {
+		if _, keep := p.Applied[key]; keep {
+			continue
+		}
+		if unresolved[key] {
+			// The source is unavailable; keep the last value until it is back.
+			if current, exists := in.Current[key]; exists && current == in.Owned[key] {
+				p.Applied[key] = current
+			}
+			continue
+		}
+		// Only remove values we put there and nobody else owns; anything
+		// else now belongs to whoever changed it.
+		if len(in.ForeignOwners[key]) > 0 {
+			continue
+		}
+		if current, exists := in.Current[key]; exists && current == in.Owned[key] {
+			p.Remove = append(p.Remove, Change{Key: key, Old: current})
+		}
+	}
+
+	if reason := policy.Quota.exceeded(requested, in.Current, p.ApplyTo(in.Current)); reason != "" {
+		return p.withhold(in, reason)
+	}
+	return p
+}
+
+// Empty reports whether the plan leaves the namespace labels untouched.
+func (p Plan) Empty() bool {
+	return len(p.Add) == 0 && len(p.Update) == 0 && len(p.Remove) == 0
+}
+
+// ApplyTo returns a copy of labels with the plan's changes applied.
+func (p Plan) ApplyTo(labels map[string]string) map[string]string {
+	out := make(map[string]string, len(labels)+len(p.Add))
+	for k, v := range labels {
+		out[k] = v
+	}
+	for _, c := range p.Add {
+		out[c.Key] = c.New
+	}
+	for _, c := range p.Update {
+		out[c.Key] = c.New
+	}
+	for _, c := range p.Remove {
+		delete(out, c.Key)
+	}
+	return out
+}
+
+// SortedKeys returns the keys of m in order.
+func SortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
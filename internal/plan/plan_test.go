@@ -0,0 +1,294 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plan
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+)
+
+var _ = Describe("Compute", func() {
+	policy := DefaultPolicy()
+
+	It("adds, updates and adopts requested labels", func() {
+		p := Compute(Input{
+			Current: map[string]string{"team": "a", "env": "dev"},
+			Desired: map[string]string{"team": "b", "env": "dev", "tier": "gold"},
+		}, policy)
+
+		Expect(p.Add).To(ConsistOf(Change{Key: "tier", New: "gold"}))
+		Expect(p.Update).To(ConsistOf(Change{Key: "team", Old: "a", New: "b"}))
+		Expect(p.Remove).To(BeEmpty())
+		Expect(p.Applied).To(Equal(map[string]string{"team": "b", "env": "dev", "tier": "gold"}))
+	})
+
+	It("removes owned labels that are no longer requested", func() {
+		p := Compute(Input{
+			Current: map[string]string{"team": "a", "env": "manual"},
+			Owned:   map[string]string{"team": "a", "env": "dev"},
+		}, policy)
+
+		Expect(p.Remove).To(ConsistOf(Change{Key: "team", Old: "a"}))
+		Expect(p.ApplyTo(map[string]string{"team": "a", "env": "manual"})).
+			To(Equal(map[string]string{"env": "manual"}))
+	})
+
+	It("restores owned labels changed outside the operator", func() {
+		p := Compute(Input{
+			Current: map[string]string{"team": "other"},
+			Desired: map[string]string{"team": "a"},
+			Owned:   map[string]string{"team": "a"},
+		}, policy)
+
+		Expect(p.Update).To(ConsistOf(Change{Key: "team", Old: "other", New: "a"}))
+		Expect(p.Drifted).To(ConsistOf("team"))
+	})
+
+	It("rejects protected, invalid and conflicting keys", func() {
+		p := Compute(Input{
+			Desired: map[string]string{
+				"kubernetes.io/metadata.name": "x",
+				"node.kubernetes.io/role":     "x",
+				"team":                        "not a valid value",
+				"owner":                       "me",
+			},
+			Claimed: map[string]string{"owner": "other"},
+		}, policy)
+
+		Expect(p.Empty()).To(BeTrue())
+		Expect(p.Rejected).To(HaveLen(4))
+		reasons := map[string]string{}
+		for _, r := range p.Rejected {
+			reasons[r.Key] = r.Reason
+		}
+		Expect(reasons).To(Equal(map[string]string{
+			"kubernetes.io/metadata.name": danaiov1alpha1.RejectedReasonProtected,
+			"node.kubernetes.io/role":     danaiov1alpha1.RejectedReasonProtected,
+			"team":                        danaiov1alpha1.RejectedReasonInvalid,
+			"owner":                       danaiov1alpha1.RejectedReasonConflict,
+		}))
+	})
+
+	It("prefers values read from sources and keeps owned ones whose source is gone", func() {
+		p := Compute(Input{
+			Current: map[string]string{"team": "a", "owner": "alice"},
+			Desired: map[string]string{"team": "literal"},
+			From:    map[string]string{"team": "b"},
+			Unresolved: []danaiov1alpha1.RejectedLabel{{
+				Key: "owner", Reason: danaiov1alpha1.RejectedReasonUnresolved, Message: "gone",
+			}},
+			Owned: map[string]string{"team": "a", "owner": "alice"},
+		}, policy)
+
+		Expect(p.Update).To(ConsistOf(Change{Key: "team", Old: "a", New: "b"}))
+		Expect(p.Remove).To(BeEmpty())
+		Expect(p.Applied).To(Equal(map[string]string{"team": "b", "owner": "alice"}))
+		Expect(p.Rejected).To(ConsistOf(HaveField("Key", "owner")))
+	})
+
+	It("allows Secrets matching the policy patterns only", func() {
+		Expect(policy.AllowsSecret("team-info")).To(BeFalse())
+		Expect(Policy{AllowedSecrets: []string{"team-*"}}.AllowsSecret("team-info")).To(BeTrue())
+		Expect(Policy{AllowedSecrets: []string{"team-*"}}.AllowsSecret("db-password")).To(BeFalse())
+	})
+
+	It("allows reading other namespaces matching the policy patterns only", func() {
+		Expect(policy.AllowsNamespaceRef("team-a", "team-a")).To(BeTrue())
+		Expect(policy.AllowsNamespaceRef("team-a", "platform")).To(BeFalse())
+		Expect(Policy{AllowedNamespaceRefs: []string{"plat*"}}.AllowsNamespaceRef("team-a", "platform")).To(BeTrue())
+	})
+
+	It("writes renamed keys under both names while dual-writing", func() {
+		renaming := DefaultPolicy()
+		renaming.Renames = []Rename{{From: "team", To: "dana.io/team", DualWrite: true}}
+
+		p := Compute(Input{Desired: map[string]string{"team": "a"}}, renaming)
+		Expect(p.Applied).To(Equal(map[string]string{"team": "a", "dana.io/team": "a"}))
+		Expect(p.Deprecated).To(Equal([]string{"team"}))
+
+		p = Compute(Input{Desired: map[string]string{"dana.io/team": "b"}}, renaming)
+		Expect(p.Applied).To(Equal(map[string]string{"team": "b", "dana.io/team": "b"}))
+		Expect(p.Deprecated).To(BeEmpty())
+
+		By("ending the transition")
+		renaming.Renames[0].DualWrite = false
+		p = Compute(Input{
+			Current: map[string]string{"team": "a", "dana.io/team": "a"},
+			Desired: map[string]string{"team": "a"},
+			Owned:   map[string]string{"team": "a", "dana.io/team": "a"},
+		}, renaming)
+		Expect(p.Remove).To(ConsistOf(Change{Key: "team", Old: "a"}))
+		Expect(p.Applied).To(Equal(map[string]string{"dana.io/team": "a"}))
+		Expect(p.Deprecated).To(Equal([]string{"team"}))
+	})
+
+	It("leaves labels other field managers own to them", func() {
+		ns := &metav1.ObjectMeta{
+			Labels: map[string]string{"team": "a", "env": "prod", "tier": "gold"},
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "argocd", FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:team":{}}}}`)}},
+				{Manager: "kubectl-edit", FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:env":{}}}}`)}},
+				{Manager: FieldManager, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:tier":{}}}}`)}},
+			},
+		}
+		Expect(policy.ForeignOwners(ns)).To(Equal(map[string][]string{"team": {"argocd"}}))
+
+		p := Compute(Input{
+			Current:       ns.Labels,
+			Desired:       map[string]string{"team": "b", "env": "dev"},
+			Owned:         map[string]string{"team": "a", "env": "dev", "tier": "gold"},
+			ForeignOwners: policy.ForeignOwners(ns),
+		}, policy)
+		Expect(p.Update).To(ConsistOf(Change{Key: "env", Old: "prod", New: "dev"}))
+		Expect(p.Remove).To(ConsistOf(Change{Key: "tier", Old: "gold"}))
+		Expect(p.Rejected).To(ConsistOf(HaveField("Reason", danaiov1alpha1.RejectedReasonForeignOwner)))
+		Expect(p.ForeignOwners).To(Equal([]danaiov1alpha1.ForeignOwner{{Key: "team", Managers: []string{"argocd"}}}))
+
+		By("removing a key only a foreign manager owns now")
+		p = Compute(Input{
+			Current:       ns.Labels,
+			Owned:         map[string]string{"team": "a"},
+			ForeignOwners: policy.ForeignOwners(ns),
+		}, policy)
+		Expect(p.Empty()).To(BeTrue())
+	})
+
+	It("withholds every change when the quota is exceeded", func() {
+		quota := DefaultPolicy()
+		quota.Quota = Quota{MaxLabelsPerObject: 2, MaxLabelsPerNamespace: 3}
+
+		p := Compute(Input{
+			Current: map[string]string{"team": "a", "manual": "yes"},
+			Desired: map[string]string{"team": "b", "env": "dev", "tier": "gold"},
+			Owned:   map[string]string{"team": "a"},
+		}, quota)
+		Expect(p.Empty()).To(BeTrue())
+		Expect(p.Rejected).To(BeEmpty())
+		Expect(p.Applied).To(Equal(map[string]string{"team": "a"}))
+		Expect(p.QuotaExceeded).To(ContainSubstring("requests 3 labels"))
+
+		p = Compute(Input{
+			Current: map[string]string{"team": "a", "manual": "yes"},
+			Desired: map[string]string{"team": "a", "env": "dev"},
+			Owned:   map[string]string{"team": "a"},
+		}, quota)
+		Expect(p.Add).To(ConsistOf(Change{Key: "env", New: "dev"}))
+
+		p = Compute(Input{
+			Current: map[string]string{"team": "a", "manual": "yes", "other": "x"},
+			Desired: map[string]string{"team": "a", "env": "dev"},
+			Owned:   map[string]string{"team": "a"},
+		}, quota)
+		Expect(p.Empty()).To(BeTrue())
+		Expect(p.QuotaExceeded).To(ContainSubstring("would leave 4 labels"))
+
+		By("letting a namespace over the quota shrink")
+		p = Compute(Input{
+			Current: map[string]string{"team": "a", "env": "dev", "manual": "yes", "other": "x"},
+			Desired: map[string]string{"team": "a"},
+			Owned:   map[string]string{"team": "a", "env": "dev"},
+		}, quota)
+		Expect(p.QuotaExceeded).To(BeEmpty())
+		Expect(p.Remove).To(ConsistOf(Change{Key: "env", Old: "dev"}))
+
+		By("capping the size of the labels")
+		quota.Quota = Quota{MaxLabelBytesPerNamespace: 16}
+		p = Compute(Input{
+			Current: map[string]string{"team": "a"},
+			Desired: map[string]string{"owner": "someone"},
+		}, quota)
+		Expect(p.QuotaExceeded).To(ContainSubstring("would leave 17 bytes"))
+	})
+
+	It("allows keys that only resemble a protected prefix", func() {
+		Expect(policy.Check("notkubernetes.io/team", "a")).To(BeNil())
+		Expect(policy.Check("example.com/kubernetes.io", "a")).To(BeNil())
+	})
+})
+
+var _ = Describe("Pod Security", func() {
+	podSecurity := &danaiov1alpha1.PodSecurity{
+		Enforce: &danaiov1alpha1.PodSecurityMode{Level: danaiov1alpha1.PodSecurityBaseline, Version: "v1.30"},
+		Warn:    &danaiov1alpha1.PodSecurityMode{Level: danaiov1alpha1.PodSecurityRestricted},
+	}
+
+	It("translates spec.podSecurity into the admission labels", func() {
+		Expect(PodSecurityLabels(podSecurity)).To(Equal(map[string]string{
+			"pod-security.kubernetes.io/enforce":         "baseline",
+			"pod-security.kubernetes.io/enforce-version": "v1.30",
+			"pod-security.kubernetes.io/warn":            "restricted",
+		}))
+		Expect(PodSecurityLabels(nil)).To(BeNil())
+	})
+
+	It("applies the labels despite the protected prefix and over spec.labels", func() {
+		p := Compute(Input{
+			Desired: map[string]string{
+				"pod-security.kubernetes.io/enforce": "privileged",
+				"pod-security.kubernetes.io/audit":   "privileged",
+			},
+			PodSecurity: PodSecurityLabels(podSecurity),
+		}, DefaultPolicy())
+
+		Expect(p.Applied).To(Equal(map[string]string{
+			"pod-security.kubernetes.io/enforce":         "baseline",
+			"pod-security.kubernetes.io/enforce-version": "v1.30",
+			"pod-security.kubernetes.io/warn":            "restricted",
+		}))
+		Expect(p.Rejected).To(ConsistOf(HaveField("Key", "pod-security.kubernetes.io/audit")))
+	})
+
+	It("rejects the labels outside spec.podSecurity whatever the protected prefixes are", func() {
+		for _, prefixes := range [][]string{{"example.com"}, {}} {
+			policy := DefaultPolicy()
+			policy.ProtectedPrefixes = prefixes
+			p := Compute(Input{
+				Desired: map[string]string{"pod-security.kubernetes.io/enforce": "privileged"},
+				From:    map[string]string{"pod-security.kubernetes.io/warn": "privileged"},
+			}, policy)
+
+			Expect(p.Applied).To(BeEmpty())
+			Expect(p.Rejected).To(ConsistOf(
+				danaiov1alpha1.RejectedLabel{
+					Key:     "pod-security.kubernetes.io/enforce",
+					Reason:  danaiov1alpha1.RejectedReasonProtected,
+					Message: "Pod Security labels can only be requested through spec.podSecurity",
+				},
+				HaveField("Key", "pod-security.kubernetes.io/warn"),
+			))
+		}
+	})
+
+	It("rejects enforce levels more privileged than the policy allows", func() {
+		policy := DefaultPolicy()
+		policy.MaxPodSecurityLevel = danaiov1alpha1.PodSecurityRestricted
+		p := Compute(Input{PodSecurity: PodSecurityLabels(podSecurity)}, policy)
+
+		Expect(p.Applied).NotTo(HaveKey("pod-security.kubernetes.io/enforce"))
+		Expect(p.Rejected).To(ConsistOf(danaiov1alpha1.RejectedLabel{
+			Key:     "pod-security.kubernetes.io/enforce",
+			Reason:  danaiov1alpha1.RejectedReasonTooPrivileged,
+			Message: `level "baseline" is more privileged than the allowed "restricted"`,
+		}))
+
+		Expect(Policy{}.CheckPodSecurity("pod-security.kubernetes.io/enforce", "restricted")).To(BeNil())
+		Expect(DefaultPolicy().CheckPodSecurity("pod-security.kubernetes.io/enforce", "privileged")).NotTo(BeNil())
+	})
+})
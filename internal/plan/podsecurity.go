@@ -0,0 +1,91 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plan
+
+import (
+	"fmt"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+)
+
+// PodSecurityPrefix is the prefix of the Pod Security Admission labels.
+const PodSecurityPrefix = "pod-security.kubernetes.io/"
+
+// DefaultMaxPodSecurityLevel is the most privileged enforce level tenants may
+// request unless configured otherwise.
+const DefaultMaxPodSecurityLevel = danaiov1alpha1.PodSecurityBaseline
+
+// podSecurityRank orders the levels from the least to the most privileged.
+var podSecurityRank = map[danaiov1alpha1.PodSecurityLevel]int{
+	danaiov1alpha1.PodSecurityRestricted: 0,
+	danaiov1alpha1.PodSecurityBaseline:   1,
+	danaiov1alpha1.PodSecurityPrivileged: 2,
+}
+
+// PodSecurityLabels returns the Pod Security Admission labels for ps, e.g.
+// pod-security.kubernetes.io/enforce and enforce-version.
+func PodSecurityLabels(ps *danaiov1alpha1.PodSecurity) map[string]string {
+	if ps == nil {
+		return nil
+	}
+	labels := map[string]string{}
+	for mode, m := range map[string]*danaiov1alpha1.PodSecurityMode{
+		"enforce": ps.Enforce,
+		"audit":   ps.Audit,
+		"warn":    ps.Warn,
+	} {
+		if m == nil {
+			continue
+		}
+		labels[PodSecurityPrefix+mode] = string(m.Level)
+		if m.Version != "" {
+			labels[PodSecurityPrefix+mode+"-version"] = m.Version
+		}
+	}
+	return labels
+}
+
+// CheckPodSecurity returns the rejection for a label translated from
+// spec.podSecurity, or nil if it may be applied. Such labels are exempt from
+// the protected prefixes, but the enforce level is capped by
+// MaxPodSecurityLevel.
+func (p Policy) CheckPodSecurity(key, value string) *danaiov1alpha1.RejectedLabel {
+	if key != PodSecurityPrefix+"enforce" {
+		return nil
+	}
+	allowed := p.MaxPodSecurityLevel
+	if allowed == "" {
+		allowed = danaiov1alpha1.PodSecurityRestricted
+	}
+	level := danaiov1alpha1.PodSecurityLevel(value)
+	rank, ok := podSecurityRank[level]
+	if !ok {
+		return &danaiov1alpha1.RejectedLabel{
+			Key:     key,
+			Reason:  danaiov1alpha1.RejectedReasonInvalid,
+			Message: fmt.Sprintf("unknown Pod Security level %q", value),
+		}
+	}
+	if rank > podSecurityRank[allowed] {
+		return &danaiov1alpha1.RejectedLabel{
+			Key:     key,
+			Reason:  danaiov1alpha1.RejectedReasonTooPrivileged,
+			Message: fmt.Sprintf("level %q is more privileged than the allowed %q", level, allowed),
+		}
+	}
+	return nil
+}
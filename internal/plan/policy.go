@@ -0,0 +1,144 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plan
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+)
+
+// DefaultProtectedPrefixes are the label key prefixes reserved for Kubernetes
+// and its core components. A key is protected when its prefix equals one of
+// these domains or is a subdomain of one.
+var DefaultProtectedPrefixes = []string{
+	"kubernetes.io",
+	"k8s.io",
+}
+
+// Policy decides which label keys a NamespaceLabel may set.
+type Policy struct {
+	// ProtectedPrefixes are the key prefixes tenants may not set.
+	ProtectedPrefixes []string
+	// MaxPodSecurityLevel is the most privileged Pod Security enforce level
+	// a NamespaceLabel may request through spec.podSecurity. Empty allows
+	// only restricted.
+	MaxPodSecurityLevel danaiov1alpha1.PodSecurityLevel
+	// AllowedSecrets are path.Match patterns of the Secret names whose keys
+	// spec.labelsFrom may copy into labels. Empty allows none.
+	AllowedSecrets []string
+	// AllowedNamespaceRefs are path.Match patterns of the Namespaces whose
+	// labels spec.labelsFrom may copy, besides the NamespaceLabel's own.
+	// Empty allows none.
+	AllowedNamespaceRefs []string
+	// Renames move labels from deprecated keys to their replacements.
+	Renames []Rename
+	// OverrideFieldManagers are path.Match patterns of field managers whose
+	// changes to requested labels are set back. The operator yields to any
+	// other field manager owning a requested key with a different value.
+	OverrideFieldManagers []string
+	// Quota caps the number and size of the labels on a namespace.
+	Quota Quota
+}
+
+// AllowsSecret reports whether spec.labelsFrom may read the Secret called
+// name.
+func (p Policy) AllowsSecret(name string) bool {
+	for _, pattern := range p.AllowedSecrets {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsNamespaceRef reports whether spec.labelsFrom of a NamespaceLabel in
+// namespace may read the labels of the Namespace called name.
+func (p Policy) AllowsNamespaceRef(namespace, name string) bool {
+	if name == namespace {
+		return true
+	}
+	for _, pattern := range p.AllowedNamespaceRefs {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultPolicy returns the policy used when none is configured.
+func DefaultPolicy() Policy {
+	return Policy{
+		ProtectedPrefixes:     DefaultProtectedPrefixes,
+		MaxPodSecurityLevel:   DefaultMaxPodSecurityLevel,
+		OverrideFieldManagers: DefaultOverrideFieldManagers,
+	}
+}
+
+// Check returns the rejection for key and value, or nil if the policy allows
+// the label to be applied.
+func (p Policy) Check(key, value string) *danaiov1alpha1.RejectedLabel {
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return &danaiov1alpha1.RejectedLabel{
+			Key:     key,
+			Reason:  danaiov1alpha1.RejectedReasonInvalid,
+			Message: strings.Join(errs, "; "),
+		}
+	}
+	if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+		return &danaiov1alpha1.RejectedLabel{
+			Key:     key,
+			Reason:  danaiov1alpha1.RejectedReasonInvalid,
+			Message: strings.Join(errs, "; "),
+		}
+	}
+	// Whatever the protected prefixes are, the Pod Security labels may only
+	// come from spec.podSecurity, where CheckPodSecurity caps the level.
+	if strings.HasPrefix(key, PodSecurityPrefix) {
+		return &danaiov1alpha1.RejectedLabel{
+			Key:     key,
+			Reason:  danaiov1alpha1.RejectedReasonProtected,
+			Message: "Pod Security labels can only be requested through spec.podSecurity",
+		}
+	}
+	if prefix, ok := p.protectedPrefix(key); ok {
+		return &danaiov1alpha1.RejectedLabel{
+			Key:     key,
+			Reason:  danaiov1alpha1.RejectedReasonProtected,
+			Message: fmt.Sprintf("keys under %q are reserved", prefix),
+		}
+	}
+	return nil
+}
+
+// protectedPrefix returns the configured prefix that protects key, if any.
+func (p Policy) protectedPrefix(key string) (string, bool) {
+	domain, _, found := strings.Cut(key, "/")
+	if !found {
+		return "", false
+	}
+	for _, prefix := range p.ProtectedPrefixes {
+		if domain == prefix || strings.HasSuffix(domain, "."+prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plan
+
+import "fmt"
+
+// Quota caps the labels a NamespaceLabel may put on its namespace. A zero
+// field is no limit. A plan exceeding any of them is withheld as a whole.
+type Quota struct {
+	// MaxLabelsPerNamespace caps the labels of a namespace, whoever set them.
+	MaxLabelsPerNamespace int
+	// MaxLabelsPerObject caps the keys a single NamespaceLabel requests.
+	MaxLabelsPerObject int
+	// MaxLabelBytesPerNamespace caps the summed length of the keys and
+	// values of the labels of a namespace.
+	MaxLabelBytesPerNamespace int
+}
+
+// exceeded returns why a NamespaceLabel requesting requested keys and
+// changing the namespace labels from before to after breaks q, or "" if it
+// does not. A namespace already over a namespace-wide limit may still shrink.
+func (q Quota) exceeded(requested int, before, after map[string]string) string {
+	if q.MaxLabelsPerObject > 0 && requested > q.MaxLabelsPerObject {
+		return fmt.Sprintf("requests %d labels, more than the limit of %d per NamespaceLabel",
+			requested, q.MaxLabelsPerObject)
+	}
+	if limit := q.MaxLabelsPerNamespace; limit > 0 && len(after) > limit && len(after) > len(before) {
+		return fmt.Sprintf("would leave %d labels on the namespace, more than the limit of %d",
+			len(after), limit)
+	}
+	if limit, size := q.MaxLabelBytesPerNamespace, LabelBytes(after); limit > 0 && size > limit &&
+		size > LabelBytes(before) {
+		return fmt.Sprintf("would leave %d bytes of labels on the namespace, more than the limit of %d",
+			size, limit)
+	}
+	return ""
+}
+
+// LabelBytes returns the summed length of the keys and values of labels.
+func LabelBytes(labels map[string]string) int {
+	size := 0
+	for key, value := range labels {
+		size += len(key) + len(value)
+	}
+	return size
+}
+
+// withhold returns p stripped of every change because of reason. The
+// NamespaceLabel keeps the labels it owns that are still on the namespace.
+func (p Plan) withhold(in Input, reason string) Plan {
+	kept := map[string]string{}
+	for key, owned := range in.Owned {
+		if current, exists := in.Current[key]; exists && current == owned {
+			kept[key] = current
+		}
+	}
+	return Plan{
+		Applied:       kept,
+		Deprecated:    p.Deprecated,
+		ForeignOwners: p.ForeignOwners,
+		QuotaExceeded: reason,
+	}
+}
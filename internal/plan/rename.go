@@ -0,0 +1,83 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plan
+
+import (
+	"sort"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+)
+
+// Rename moves a label from a deprecated key to its replacement.
+type Rename struct {
+	// From is the deprecated key.
+	From string
+	// To is the key replacing it.
+	To string
+	// DualWrite keeps writing From next to To while consumers of the label
+	// migrate, whichever of the two a NamespaceLabel requests.
+	DualWrite bool
+}
+
+// rename rewrites desired according to the renames of p and returns the
+// deprecated keys it contained, sorted. A requested deprecated key is also
+// written under its replacement, unless that is requested too; it is only
+// kept itself while the rename dual-writes.
+func (p Policy) rename(desired map[string]string) []string {
+	var deprecated []string
+	for _, r := range p.Renames {
+		value, hasFrom := desired[r.From]
+		replacement, hasTo := desired[r.To]
+		switch {
+		case hasFrom:
+			deprecated = append(deprecated, r.From)
+			if !hasTo {
+				desired[r.To] = value
+			}
+			if !r.DualWrite {
+				delete(desired, r.From)
+			}
+		case hasTo && r.DualWrite:
+			desired[r.From] = replacement
+		}
+	}
+	sort.Strings(deprecated)
+	return deprecated
+}
+
+// Replacement returns the key replacing the deprecated key, if any.
+func (p Policy) Replacement(key string) (string, bool) {
+	for _, r := range p.Renames {
+		if r.From == key {
+			return r.To, true
+		}
+	}
+	return "", false
+}
+
+// DeprecatedKeys returns the deprecated keys nl requests through spec.labels
+// or spec.labelsFrom, sorted.
+func (p Policy) DeprecatedKeys(nl *danaiov1alpha1.NamespaceLabel) []string {
+	requested := map[string]string{}
+	for key, value := range nl.Spec.Labels {
+		requested[key] = value
+	}
+	for _, from := range nl.Spec.LabelsFrom {
+		requested[from.Key] = ""
+	}
+	return p.rename(requested)
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sources reads the values NamespaceLabels copy from other objects
+// through spec.labelsFrom.
+package sources
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+// IndexField is the NamespaceLabel field index holding the objects its
+// spec.labelsFrom reads, in the form returned by Key.
+const IndexField = "spec.labelsFrom.source"
+
+// Kinds of the objects a label value may be read from.
+const (
+	KindConfigMap = "ConfigMap"
+	KindSecret    = "Secret"
+	KindNamespace = "Namespace"
+)
+
+// Key identifies a source object in IndexField. Namespaces are cluster
+// scoped and use an empty namespace.
+func Key(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
+
+// IndexValues returns the IndexField values of a NamespaceLabel.
+func IndexValues(obj client.Object) []string {
+	nl, ok := obj.(*danaiov1alpha1.NamespaceLabel)
+	if !ok {
+		return nil
+	}
+	var keys []string
+	for _, from := range nl.Spec.LabelsFrom {
+		source := from.ValueFrom
+		switch {
+		case source.ConfigMapKeyRef != nil:
+			keys = append(keys, Key(KindConfigMap, nl.Namespace, source.ConfigMapKeyRef.Name))
+		case source.SecretKeyRef != nil:
+			keys = append(keys, Key(KindSecret, nl.Namespace, source.SecretKeyRef.Name))
+		case source.NamespaceRef != nil:
+			keys = append(keys, Key(KindNamespace, "", source.NamespaceRef.Name))
+		}
+	}
+	return keys
+}
+
+// Resolve reads the values of the spec.labelsFrom entries of nl. Sources
+// that do not exist or that policy forbids are returned as rejected keys,
+// unless the entry is optional; other read errors are returned.
+//
+// The reader should not be backed by a cache: the controller only watches
+// the metadata of ConfigMaps and Secrets, never their data.
+func Resolve(ctx context.Context, reader client.Reader, nl *danaiov1alpha1.NamespaceLabel,
+	policy plan.Policy) (plan.Resolved, error) {
+	resolved := plan.Resolved{Values: map[string]string{}}
+	reject := func(key, reason, message string) {
+		resolved.Rejected = append(resolved.Rejected, danaiov1alpha1.RejectedLabel{
+			Key: key, Reason: reason, Message: message,
+		})
+	}
+
+	for _, from := range nl.Spec.LabelsFrom {
+		var (
+			value, what string
+			found       bool
+			optional    *bool
+			err         error
+		)
+		source := from.ValueFrom
+		switch {
+		case source.ConfigMapKeyRef != nil:
+			ref := source.ConfigMapKeyRef
+			optional = ref.Optional
+			what = fmt.Sprintf("key %q of ConfigMap %s", ref.Key, ref.Name)
+			var cm corev1.ConfigMap
+			if err = reader.Get(ctx, client.ObjectKey{Namespace: nl.Namespace, Name: ref.Name}, &cm); err == nil {
+				value, found = cm.Data[ref.Key]
+			}
+		case source.SecretKeyRef != nil:
+			ref := source.SecretKeyRef
+			optional = ref.Optional
+			what = fmt.Sprintf("key %q of Secret %s", ref.Key, ref.Name)
+			if !policy.AllowsSecret(ref.Name) {
+				reject(from.Key, danaiov1alpha1.RejectedReasonForbiddenSource,
+					fmt.Sprintf("the policy does not allow reading Secret %s", ref.Name))
+				continue
+			}
+			var secret corev1.Secret
+			if err = reader.Get(ctx, client.ObjectKey{Namespace: nl.Namespace, Name: ref.Name}, &secret); err == nil {
+				var data []byte
+				data, found = secret.Data[ref.Key]
+				value = string(data)
+			}
+		case source.NamespaceRef != nil:
+			ref := source.NamespaceRef
+			optional = ref.Optional
+			what = fmt.Sprintf("label %q of Namespace %s", ref.Key, ref.Name)
+			if !policy.AllowsNamespaceRef(nl.Namespace, ref.Name) {
+				reject(from.Key, danaiov1alpha1.RejectedReasonForbiddenSource,
+					fmt.Sprintf("the policy does not allow reading the labels of Namespace %s", ref.Name))
+				continue
+			}
+			ns := &metav1.PartialObjectMetadata{}
+			ns.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+			if err = reader.Get(ctx, client.ObjectKey{Name: ref.Name}, ns); err == nil {
+				value, found = ns.Labels[ref.Key]
+			}
+		default:
+			continue
+		}
+
+		switch {
+		case err != nil && !apierrors.IsNotFound(err):
+			return plan.Resolved{}, fmt.Errorf("reading %s for label %s: %w", what, from.Key, err)
+		case found:
+			resolved.Values[from.Key] = value
+		case optional != nil && *optional:
+			// An optional key without a source is simply not requested.
+		default:
+			reject(from.Key, danaiov1alpha1.RejectedReasonUnresolved, what+" does not exist")
+		}
+	}
+	return resolved, nil
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sources
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+var _ = Describe("Resolve", func() {
+	var (
+		ctx context.Context
+		c   client.Client
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+		c = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "team-info"},
+				Data:       map[string]string{"team": "payments"},
+			},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "billing"},
+				Data:       map[string][]byte{"cost-center": []byte("cc-42")},
+			},
+			&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "platform", Labels: map[string]string{"region": "eu"}},
+			},
+		).Build()
+	})
+
+	namespaceLabel := func(from ...danaiov1alpha1.LabelFrom) *danaiov1alpha1.NamespaceLabel {
+		return &danaiov1alpha1.NamespaceLabel{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "labels"},
+			Spec:       danaiov1alpha1.NamespaceLabelSpec{LabelsFrom: from},
+		}
+	}
+	configMapKey := func(name, key string) danaiov1alpha1.LabelValueSource {
+		return danaiov1alpha1.LabelValueSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name}, Key: key,
+		}}
+	}
+	secretKey := danaiov1alpha1.LabelValueSource{SecretKeyRef: &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "billing"}, Key: "cost-center",
+	}}
+	namespaceKey := func(key string, optional bool) danaiov1alpha1.LabelValueSource {
+		return danaiov1alpha1.LabelValueSource{NamespaceRef: &danaiov1alpha1.NamespaceKeySelector{
+			Name: "platform", Key: key, Optional: &optional,
+		}}
+	}
+
+	It("reads ConfigMap keys, allowed Secret keys and Namespace labels", func() {
+		nl := namespaceLabel(
+			danaiov1alpha1.LabelFrom{Key: "team", ValueFrom: configMapKey("team-info", "team")},
+			danaiov1alpha1.LabelFrom{Key: "cost-center", ValueFrom: secretKey},
+			danaiov1alpha1.LabelFrom{Key: "region", ValueFrom: namespaceKey("region", false)},
+		)
+		resolved, err := Resolve(ctx, c, nl, plan.Policy{
+			AllowedSecrets:       []string{"bill*"},
+			AllowedNamespaceRefs: []string{"plat*"},
+		})
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resolved.Values).To(Equal(map[string]string{
+			"team": "payments", "cost-center": "cc-42", "region": "eu",
+		}))
+		Expect(resolved.Rejected).To(BeEmpty())
+		Expect(IndexValues(nl)).To(ConsistOf(
+			"ConfigMap/team-a/team-info", "Secret/team-a/billing", "Namespace//platform"))
+	})
+
+	It("rejects missing and forbidden sources unless they are optional", func() {
+		nl := namespaceLabel(
+			danaiov1alpha1.LabelFrom{Key: "team", ValueFrom: configMapKey("missing", "team")},
+			danaiov1alpha1.LabelFrom{Key: "owner", ValueFrom: configMapKey("team-info", "owner")},
+			danaiov1alpha1.LabelFrom{Key: "cost-center", ValueFrom: secretKey},
+			danaiov1alpha1.LabelFrom{Key: "zone", ValueFrom: namespaceKey("zone", true)},
+			danaiov1alpha1.LabelFrom{Key: "tenant", ValueFrom: danaiov1alpha1.LabelValueSource{
+				NamespaceRef: &danaiov1alpha1.NamespaceKeySelector{Name: "team-b", Key: "tenant"},
+			}},
+		)
+		policy := plan.DefaultPolicy()
+		policy.AllowedNamespaceRefs = []string{"plat*"}
+		resolved, err := Resolve(ctx, c, nl, policy)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resolved.Values).To(BeEmpty())
+		Expect(resolved.Rejected).To(ConsistOf(
+			danaiov1alpha1.RejectedLabel{
+				Key: "team", Reason: danaiov1alpha1.RejectedReasonUnresolved,
+				Message: `key "team" of ConfigMap missing does not exist`,
+			},
+			danaiov1alpha1.RejectedLabel{
+				Key: "owner", Reason: danaiov1alpha1.RejectedReasonUnresolved,
+				Message: `key "owner" of ConfigMap team-info does not exist`,
+			},
+			danaiov1alpha1.RejectedLabel{
+				Key: "cost-center", Reason: danaiov1alpha1.RejectedReasonForbiddenSource,
+				Message: "the policy does not allow reading Secret billing",
+			},
+			danaiov1alpha1.RejectedLabel{
+				Key: "tenant", Reason: danaiov1alpha1.RejectedReasonForbiddenSource,
+				Message: "the policy does not allow reading the labels of Namespace team-b",
+			},
+		))
+	})
+})
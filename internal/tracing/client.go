@@ -0,0 +1,159 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// WrapClient returns c with a span around every call that reaches the cache
+// or the API server.
+func WrapClient(c client.Client) client.Client {
+	return &tracingClient{Client: c}
+}
+
+// WrapReader returns r, such as the manager's uncached APIReader, with a span
+// around every call. Object kinds are looked up in scheme.
+func WrapReader(r client.Reader, scheme *runtime.Scheme) client.Reader {
+	return &tracingReader{Reader: r, scheme: scheme}
+}
+
+type tracingClient struct {
+	client.Client
+}
+
+// startSpan opens a span for verb on the object of kind obj identified by key.
+func (c *tracingClient) startSpan(ctx context.Context, verb string, obj runtime.Object,
+	key client.ObjectKey) (context.Context, trace.Span) {
+	return startSpan(ctx, verb, c.GroupVersionKindFor, obj, key)
+}
+
+// startSpan opens a span for verb on the object of kind obj, as told by
+// kindFor, identified by key.
+func startSpan(ctx context.Context, verb string, kindFor func(runtime.Object) (schema.GroupVersionKind, error),
+	obj runtime.Object, key client.ObjectKey) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{attribute.String("k8s.verb", verb)}
+	if gvk, err := kindFor(obj); err == nil {
+		attrs = append(attrs, attribute.String("k8s.kind", gvk.Kind))
+	}
+	if key.Namespace != "" {
+		attrs = append(attrs, attribute.String("k8s.namespace", key.Namespace))
+	}
+	if key.Name != "" {
+		attrs = append(attrs, attribute.String("k8s.name", key.Name))
+	}
+	return Start(ctx, "client."+verb, attrs...)
+}
+
+func (c *tracingClient) start(ctx context.Context, verb string, obj client.Object) (context.Context, trace.Span) {
+	return c.startSpan(ctx, verb, obj, client.ObjectKeyFromObject(obj))
+}
+
+func (c *tracingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object,
+	opts ...client.GetOption) (err error) {
+	ctx, span := c.startSpan(ctx, "Get", obj, key)
+	defer func() { End(span, err) }()
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c *tracingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) (err error) {
+	ctx, span := c.startSpan(ctx, "List", list, listKey(opts))
+	defer func() { End(span, err) }()
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c *tracingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) (err error) {
+	ctx, span := c.start(ctx, "Create", obj)
+	defer func() { End(span, err) }()
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *tracingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) (err error) {
+	ctx, span := c.start(ctx, "Update", obj)
+	defer func() { End(span, err) }()
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *tracingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) (err error) {
+	ctx, span := c.start(ctx, "Patch", obj)
+	defer func() { End(span, err) }()
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *tracingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) (err error) {
+	ctx, span := c.start(ctx, "Delete", obj)
+	defer func() { End(span, err) }()
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c *tracingClient) Status() client.SubResourceWriter {
+	return &tracingStatusWriter{SubResourceWriter: c.Client.Status(), client: c}
+}
+
+type tracingStatusWriter struct {
+	client.SubResourceWriter
+	client *tracingClient
+}
+
+func (w *tracingStatusWriter) Update(ctx context.Context, obj client.Object,
+	opts ...client.SubResourceUpdateOption) (err error) {
+	ctx, span := w.client.start(ctx, "UpdateStatus", obj)
+	defer func() { End(span, err) }()
+	return w.SubResourceWriter.Update(ctx, obj, opts...)
+}
+
+func (w *tracingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.SubResourcePatchOption) (err error) {
+	ctx, span := w.client.start(ctx, "PatchStatus", obj)
+	defer func() { End(span, err) }()
+	return w.SubResourceWriter.Patch(ctx, obj, patch, opts...)
+}
+
+// listKey returns the key naming the namespace a List is restricted to.
+func listKey(opts []client.ListOption) client.ObjectKey {
+	return client.ObjectKey{Namespace: (&client.ListOptions{}).ApplyOptions(opts).Namespace}
+}
+
+type tracingReader struct {
+	client.Reader
+	scheme *runtime.Scheme
+}
+
+func (r *tracingReader) kindFor(obj runtime.Object) (schema.GroupVersionKind, error) {
+	return apiutil.GVKForObject(obj, r.scheme)
+}
+
+func (r *tracingReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object,
+	opts ...client.GetOption) (err error) {
+	ctx, span := startSpan(ctx, "Get", r.kindFor, obj, key)
+	defer func() { End(span, err) }()
+	return r.Reader.Get(ctx, key, obj, opts...)
+}
+
+func (r *tracingReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) (err error) {
+	ctx, span := startSpan(ctx, "List", r.kindFor, list, listKey(opts))
+	defer func() { End(span, err) }()
+	return r.Reader.List(ctx, list, opts...)
+}
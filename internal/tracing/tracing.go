@@ -0,0 +1,126 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tracing wires OpenTelemetry into the manager. Spans are exported
+// over OTLP/gRPC when an endpoint is configured; otherwise the global no-op
+// provider is left in place and instrumentation costs next to nothing.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// instrumentationName identifies the spans created by this operator.
+const instrumentationName = "github.com/TalDebi/namespacelabel"
+
+// serviceName is reported as the service.name resource attribute.
+const serviceName = "namespacelabel-operator"
+
+// Options configure the OTLP exporter.
+type Options struct {
+	// Endpoint is the host:port of the OTLP/gRPC collector.
+	Endpoint string
+	// Insecure disables TLS towards the collector.
+	Insecure bool
+	// SampleRatio is the fraction of new traces that are recorded.
+	SampleRatio float64
+}
+
+// Setup installs a global tracer provider exporting to opts.Endpoint and
+// returns a function that flushes and stops it.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	exporterOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.Endpoint)}
+	if opts.Insecure {
+		exporterOpts = append(exporterOpts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("building trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// Start opens a span named name and returns a context carrying both the span
+// and a logger annotated with its trace ID, so log lines can be joined with
+// the trace.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+	return log.IntoContext(ctx, WithTraceID(log.FromContext(ctx), span)), span
+}
+
+// WithTraceID returns logger annotated with the trace and span IDs of span,
+// or logger unchanged if span is not recording.
+func WithTraceID(logger logr.Logger, span trace.Span) logr.Logger {
+	sc := span.SpanContext()
+	if !sc.IsValid() {
+		return logger
+	}
+	return logger.WithValues("traceID", sc.TraceID().String(), "spanID", sc.SpanID().String())
+}
+
+// End records err on span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// EndAdmission records on span whether an admission webhook allowed the
+// request, and ends it. A denial, an Invalid or Forbidden status error, is
+// the webhook's answer rather than a failure, so it is recorded as the
+// admission.reason attribute and leaves the span status unset.
+func EndAdmission(span trace.Span, err error) {
+	switch {
+	case err == nil:
+		span.SetAttributes(attribute.Bool("admission.allowed", true))
+	case apierrors.IsInvalid(err) || apierrors.IsForbidden(err):
+		span.SetAttributes(attribute.Bool("admission.allowed", false), attribute.String("admission.reason", err.Error()))
+		err = nil
+	}
+	End(span, err)
+}
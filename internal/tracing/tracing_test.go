@@ -0,0 +1,198 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// collector is a minimal in-process OTLP/gRPC trace collector.
+type collector struct {
+	collectortrace.UnimplementedTraceServiceServer
+
+	mu    sync.Mutex
+	spans []string
+}
+
+func (c *collector) Export(_ context.Context,
+	req *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			for _, span := range ss.GetSpans() {
+				c.spans = append(c.spans, span.GetName())
+			}
+		}
+	}
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+func (c *collector) names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.spans...)
+}
+
+// useRecorder installs a tracer provider recording into memory for the
+// current spec.
+func useRecorder() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	DeferCleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	names := make([]string, 0, len(spans))
+	for _, s := range spans {
+		names = append(names, s.Name())
+	}
+	return names
+}
+
+var _ = Describe("Setup", func() {
+	It("exports spans to the configured OTLP endpoint", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		server := grpc.NewServer()
+		received := &collector{}
+		collectortrace.RegisterTraceServiceServer(server, received)
+		go func() { _ = server.Serve(listener) }()
+		DeferCleanup(server.Stop)
+
+		previous := otel.GetTracerProvider()
+		DeferCleanup(func() { otel.SetTracerProvider(previous) })
+
+		ctx := context.Background()
+		shutdown, err := Setup(ctx, Options{Endpoint: listener.Addr().String(), Insecure: true, SampleRatio: 1})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, span := Start(ctx, "test.span")
+		End(span, nil)
+		Expect(shutdown(ctx)).To(Succeed())
+
+		Eventually(received.names).Should(ContainElement("test.span"))
+	})
+})
+
+var _ = Describe("Start", func() {
+	It("adds the trace ID to the logger in the context", func() {
+		useRecorder()
+
+		var lines []string
+		logger := funcr.New(func(_, args string) { lines = append(lines, args) }, funcr.Options{})
+
+		ctx, span := Start(log.IntoContext(context.Background(), logger), "test.span")
+		defer span.End()
+		log.FromContext(ctx).Info("reconciling")
+
+		Expect(lines).To(ConsistOf(ContainSubstring(span.SpanContext().TraceID().String())))
+	})
+})
+
+var _ = Describe("EndAdmission", func() {
+	It("records denials as decisions and other errors as failures", func() {
+		recorder := useRecorder()
+
+		_, span := Start(context.Background(), "allowed")
+		EndAdmission(span, nil)
+		_, span = Start(context.Background(), "denied")
+		EndAdmission(span, apierrors.NewInvalid(schema.GroupKind{Kind: "Namespace"}, "team-a", nil))
+		_, span = Start(context.Background(), "failed")
+		EndAdmission(span, errors.New("connection refused"))
+
+		spans := recorder.Ended()
+		Expect(spanNames(spans)).To(Equal([]string{"allowed", "denied", "failed"}))
+		Expect(spans[0].Attributes()).To(ContainElement(attribute.Bool("admission.allowed", true)))
+		Expect(spans[1].Attributes()).To(ContainElement(attribute.Bool("admission.allowed", false)))
+		Expect(spans[1].Status().Code).To(Equal(codes.Unset))
+		Expect(spans[2].Attributes()).NotTo(ContainElement(HaveField("Key", attribute.Key("admission.allowed"))))
+		Expect(spans[2].Status().Code).To(Equal(codes.Error))
+	})
+})
+
+var _ = Describe("WrapClient", func() {
+	It("opens a span per client call and records errors", func() {
+		recorder := useRecorder()
+
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+		c := WrapClient(fake.NewClientBuilder().
+			WithScheme(scheme.Scheme).
+			WithObjects(ns).
+			WithInterceptorFuncs(interceptor.Funcs{
+				Delete: func(context.Context, client.WithWatch, client.Object, ...client.DeleteOption) error {
+					return errors.New("denied")
+				},
+			}).
+			Build())
+
+		ctx := context.Background()
+		Expect(c.Get(ctx, client.ObjectKeyFromObject(ns), &corev1.Namespace{})).To(Succeed())
+		Expect(c.List(ctx, &corev1.NamespaceList{})).To(Succeed())
+		Expect(c.Patch(ctx, ns, client.MergeFrom(ns.DeepCopy()))).To(Succeed())
+		Expect(c.Delete(ctx, ns)).NotTo(Succeed())
+
+		spans := recorder.Ended()
+		Expect(spanNames(spans)).To(Equal([]string{"client.Get", "client.List", "client.Patch", "client.Delete"}))
+		Expect(spans[3].Status().Description).To(Equal("denied"))
+	})
+})
+
+var _ = Describe("WrapReader", func() {
+	It("opens a span per read with the object kind", func() {
+		recorder := useRecorder()
+
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+		r := WrapReader(fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns).Build(), scheme.Scheme)
+
+		ctx := context.Background()
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(ns), &corev1.Namespace{})).To(Succeed())
+		Expect(r.List(ctx, &corev1.ConfigMapList{}, client.InNamespace("team-a"))).To(Succeed())
+
+		spans := recorder.Ended()
+		Expect(spanNames(spans)).To(Equal([]string{"client.Get", "client.List"}))
+		Expect(spans[0].Attributes()).To(ContainElements(
+			attribute.String("k8s.kind", "Namespace"), attribute.String("k8s.name", "team-a")))
+		Expect(spans[1].Attributes()).To(ContainElements(
+			attribute.String("k8s.kind", "ConfigMapList"), attribute.String("k8s.namespace", "team-a")))
+	})
+})
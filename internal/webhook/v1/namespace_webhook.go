@@ -0,0 +1,271 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1 holds the admission webhooks for core/v1 objects.
+package v1
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/controller"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+	"github.com/TalDebi/namespacelabel/internal/tracing"
+)
+
+var namespacelog = logf.Log.WithName("namespace-resource")
+
+// SetupNamespaceWebhookWithManager registers the webhook for Namespaces in the manager.
+func SetupNamespaceWebhookWithManager(mgr ctrl.Manager, defaulter *NamespaceCustomDefaulter,
+	validator *NamespaceCustomValidator) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&corev1.Namespace{}).
+		WithDefaulter(defaulter).
+		WithValidator(validator).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate--v1-namespace,mutating=true,failurePolicy=ignore,sideEffects=None,groups="",resources=namespaces,verbs=create,versions=v1,name=mnamespace-v1.kb.io,admissionReviewVersions=v1
+
+// NamespaceCustomDefaulter adds the default labels to new namespaces and
+// lists the keys it added in controller.DefaultLabelsAnnotation, so the
+// DefaultsReconciler can create a NamespaceLabel owning them.
+type NamespaceCustomDefaulter struct {
+	// Namespaces selects the namespaces that get default labels. The
+	// selector is matched after the defaults are added.
+	Namespaces plan.NamespaceFilter
+	// DryRun only logs the default labels a new namespace would get.
+	DryRun bool
+
+	mu     sync.RWMutex
+	labels map[string]string
+}
+
+var _ webhook.CustomDefaulter = &NamespaceCustomDefaulter{}
+
+// UserPlaceholder in a default label value is replaced with the name of the
+// user creating the namespace.
+const UserPlaceholder = "${user}"
+
+// SetLabels replaces the default labels. It is safe to call while the
+// webhook serves requests.
+func (d *NamespaceCustomDefaulter) SetLabels(labels map[string]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.labels = labels
+}
+
+// Default implements webhook.CustomDefaulter. Keys the namespace already
+// sets are left alone.
+func (d *NamespaceCustomDefaulter) Default(ctx context.Context, obj runtime.Object) (err error) {
+	namespace, ok := obj.(*corev1.Namespace)
+	if !ok {
+		return fmt.Errorf("expected a Namespace object but got %T", obj)
+	}
+	ctx, span := tracing.Start(ctx, "webhook.Namespace.Default", attribute.String("k8s.name", namespace.Name))
+	defer func() { tracing.EndAdmission(span, err) }()
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	d.mu.RLock()
+	defaults := d.labels
+	d.mu.RUnlock()
+
+	labels := maps.Clone(namespace.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	var added []string
+	for key, value := range defaults {
+		if _, ok := labels[key]; !ok {
+			labels[key] = strings.ReplaceAll(value, UserPlaceholder, labelValue(req.UserInfo.Username))
+			added = append(added, key)
+		}
+	}
+	if len(added) == 0 || !d.Namespaces.Manages(namespace.Name, labels) {
+		return nil
+	}
+
+	sort.Strings(added)
+	span.SetAttributes(attribute.StringSlice("admission.added_labels", added), attribute.Bool("admission.dry_run", d.DryRun))
+	if d.DryRun {
+		namespacelog.Info("dry-run: would add default labels", "name", namespace.Name, "keys", added)
+		return nil
+	}
+	namespace.Labels = labels
+	if namespace.Annotations == nil {
+		namespace.Annotations = map[string]string{}
+	}
+	namespace.Annotations[controller.DefaultLabelsAnnotation] = strings.Join(added, ",")
+	namespacelog.Info("added default labels", "name", namespace.Name, "keys", added)
+	return nil
+}
+
+// labelValue turns s into a valid label value: characters a label value
+// cannot hold become dashes, and the result is cut to 63 characters and
+// trimmed to start and end with an alphanumeric character.
+func labelValue(s string) string {
+	value := []byte(s)
+	for i, c := range value {
+		if !isAlphanumeric(c) && c != '-' && c != '_' && c != '.' {
+			value[i] = '-'
+		}
+	}
+	if len(value) > validation.LabelValueMaxLength {
+		value = value[:validation.LabelValueMaxLength]
+	}
+	return strings.TrimFunc(string(value), func(r rune) bool { return !isAlphanumeric(byte(r)) })
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// +kubebuilder:webhook:path=/validate--v1-namespace,mutating=false,failurePolicy=ignore,sideEffects=None,groups="",resources=namespaces,verbs=update,versions=v1,name=vnamespace-v1.kb.io,admissionReviewVersions=v1
+
+// NamespaceCustomValidator denies Namespace updates that change a label owned
+// by a NamespaceLabel, so manual edits never take effect between a change and
+// the controller restoring the label. The error names the owning object.
+type NamespaceCustomValidator struct {
+	// Client reads the NamespaceLabels of the namespace being updated.
+	Client client.Reader
+
+	// AllowedUsers may change owned labels, e.g. the operator's service
+	// account.
+	AllowedUsers []string
+	// AllowedGroups may change owned labels too.
+	AllowedGroups []string
+}
+
+var _ webhook.CustomValidator = &NamespaceCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator. A new Namespace has no
+// owned labels yet.
+func (v *NamespaceCustomValidator) ValidateCreate(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateUpdate implements webhook.CustomValidator.
+func (v *NamespaceCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (_ admission.Warnings, err error) {
+	oldNamespace, ok := oldObj.(*corev1.Namespace)
+	if !ok {
+		return nil, fmt.Errorf("expected a Namespace object for the oldObj but got %T", oldObj)
+	}
+	namespace, ok := newObj.(*corev1.Namespace)
+	if !ok {
+		return nil, fmt.Errorf("expected a Namespace object for the newObj but got %T", newObj)
+	}
+	ctx, span := tracing.Start(ctx, "webhook.Namespace.ValidateUpdate", attribute.String("k8s.name", namespace.Name))
+	defer func() { tracing.EndAdmission(span, err) }()
+
+	changed := changedKeys(oldNamespace.Labels, namespace.Labels)
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	span.SetAttributes(attribute.StringSlice("admission.changed_labels", changed))
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if v.allowed(req.UserInfo.Username, req.UserInfo.Groups) {
+		span.SetAttributes(attribute.Bool("admission.allowlisted", true))
+		return nil, nil
+	}
+
+	var list danaiov1alpha1.NamespaceLabelList
+	if err := v.Client.List(ctx, &list, client.InNamespace(namespace.Name)); err != nil {
+		return nil, err
+	}
+	owners := map[string]string{}
+	for _, nl := range list.Items {
+		for key := range nl.Status.AppliedLabels {
+			owners[key] = nl.Name
+		}
+	}
+
+	var errs field.ErrorList
+	var denied []string
+	labelsPath := field.NewPath("metadata", "labels")
+	for _, key := range changed {
+		if owner, ok := owners[key]; ok {
+			errs = append(errs, field.Forbidden(labelsPath.Key(key), fmt.Sprintf(
+				"owned by NamespaceLabel %s/%s; change it there instead", namespace.Name, owner)))
+			denied = append(denied, key)
+		}
+	}
+	if len(errs) == 0 {
+		return nil, nil
+	}
+	span.SetAttributes(attribute.StringSlice("admission.denied_labels", denied))
+	namespacelog.Info("denied a change to owned labels", "name", namespace.Name, "user", req.UserInfo.Username)
+	return nil, apierrors.NewInvalid(schema.GroupKind{Kind: "Namespace"}, namespace.Name, errs)
+}
+
+// ValidateDelete implements webhook.CustomValidator. The webhook is not
+// registered for deletes.
+func (v *NamespaceCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *NamespaceCustomValidator) allowed(username string, groups []string) bool {
+	if slices.Contains(v.AllowedUsers, username) {
+		return true
+	}
+	for _, group := range groups {
+		if slices.Contains(v.AllowedGroups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// changedKeys returns the sorted keys added, removed or changed between
+// before and after.
+func changedKeys(before, after map[string]string) []string {
+	var keys []string
+	for key, value := range before {
+		if current, ok := after[key]; !ok || current != value {
+			keys = append(keys, key)
+		}
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
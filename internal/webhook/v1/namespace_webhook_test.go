@@ -0,0 +1,207 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/controller"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+var _ = Describe("Namespace Webhook", func() {
+	var (
+		validator *NamespaceCustomValidator
+		oldObj    *corev1.Namespace
+		obj       *corev1.Namespace
+	)
+
+	as := func(username string, groups ...string) context.Context {
+		return admission.NewContextWithRequest(context.Background(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo: authenticationv1.UserInfo{Username: username, Groups: groups},
+			},
+		})
+	}
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(danaiov1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		owner := &danaiov1alpha1.NamespaceLabel{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "labels"},
+			Status: danaiov1alpha1.NamespaceLabelStatus{
+				AppliedLabels: map[string]string{"team": "a", "tier": "gold"},
+			},
+		}
+		validator = &NamespaceCustomValidator{
+			Client:        fake.NewClientBuilder().WithScheme(scheme).WithObjects(owner).Build(),
+			AllowedUsers:  []string{"system:serviceaccount:nsl-system:controller-manager"},
+			AllowedGroups: []string{"platform-admins"},
+		}
+
+		oldObj = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "team-a",
+			Labels: map[string]string{"team": "a", "tier": "gold", "env": "dev"},
+		}}
+		obj = oldObj.DeepCopy()
+	})
+
+	It("denies changing and removing owned labels and names the owner", func() {
+		obj.Labels["team"] = "b"
+		delete(obj.Labels, "tier")
+
+		_, err := validator.ValidateUpdate(as("alice"), oldObj, obj)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err).To(MatchError(And(
+			ContainSubstring("metadata.labels[team]: Forbidden: owned by NamespaceLabel team-a/labels"),
+			ContainSubstring("metadata.labels[tier]"))))
+	})
+
+	It("records the decision on a span", func() {
+		recorder := useRecorder()
+		obj.Labels["team"] = "b"
+		obj.Labels["env"] = "prod"
+
+		Expect(validator.ValidateUpdate(as("alice"), oldObj, obj)).Error().To(HaveOccurred())
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name()).To(Equal("webhook.Namespace.ValidateUpdate"))
+		Expect(spans[0].Status().Code).To(Equal(codes.Unset))
+		Expect(spans[0].Attributes()).To(ContainElements(
+			attribute.Bool("admission.allowed", false),
+			attribute.StringSlice("admission.changed_labels", []string{"env", "team"}),
+			attribute.StringSlice("admission.denied_labels", []string{"team"})))
+	})
+
+	It("allows changes to labels no NamespaceLabel owns", func() {
+		obj.Labels["env"] = "prod"
+		obj.Labels["owner"] = "alice"
+
+		Expect(validator.ValidateUpdate(as("alice"), oldObj, obj)).Error().NotTo(HaveOccurred())
+	})
+
+	It("allows the operator and allowlisted groups to change owned labels", func() {
+		obj.Labels["team"] = "b"
+
+		Expect(validator.ValidateUpdate(as("system:serviceaccount:nsl-system:controller-manager"), oldObj, obj)).
+			Error().NotTo(HaveOccurred())
+		Expect(validator.ValidateUpdate(as("bob", "platform-admins"), oldObj, obj)).Error().NotTo(HaveOccurred())
+	})
+
+	It("allows updates that leave the labels alone", func() {
+		obj.Annotations = map[string]string{"note": "hi"}
+
+		Expect(validator.ValidateUpdate(context.Background(), oldObj, obj)).Error().NotTo(HaveOccurred())
+	})
+})
+
+var _ = Describe("Namespace Defaulting Webhook", func() {
+	var defaulter *NamespaceCustomDefaulter
+
+	create := func(ns *corev1.Namespace) error {
+		ctx := admission.NewContextWithRequest(context.Background(), admission.Request{
+			AdmissionRequest: admissionv1.AdmissionRequest{
+				UserInfo: authenticationv1.UserInfo{Username: "system:serviceaccount:ci:deployer"},
+			},
+		})
+		return defaulter.Default(ctx, ns)
+	}
+
+	BeforeEach(func() {
+		defaulter = &NamespaceCustomDefaulter{
+			Namespaces: plan.NamespaceFilter{Exclude: plan.DefaultExcludedNamespaces},
+		}
+		defaulter.SetLabels(map[string]string{"created-by": UserPlaceholder, "tenant": "shared"})
+	})
+
+	It("adds the default labels and lists them in an annotation", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+		Expect(create(ns)).To(Succeed())
+
+		Expect(ns.Labels).To(Equal(map[string]string{
+			"created-by": "system-serviceaccount-ci-deployer",
+			"tenant":     "shared",
+		}))
+		Expect(ns.Annotations).To(HaveKeyWithValue(controller.DefaultLabelsAnnotation, "created-by,tenant"))
+	})
+
+	It("records the labels it added on a span", func() {
+		recorder := useRecorder()
+		Expect(create(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}})).To(Succeed())
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name()).To(Equal("webhook.Namespace.Default"))
+		Expect(spans[0].Attributes()).To(ContainElements(
+			attribute.Bool("admission.allowed", true),
+			attribute.StringSlice("admission.added_labels", []string{"created-by", "tenant"}),
+			attribute.Bool("admission.dry_run", false)))
+	})
+
+	It("keeps the labels the namespace sets itself", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "team-a",
+			Labels: map[string]string{"tenant": "team-a"},
+		}}
+		Expect(create(ns)).To(Succeed())
+
+		Expect(ns.Labels).To(HaveKeyWithValue("tenant", "team-a"))
+		Expect(ns.Annotations).To(HaveKeyWithValue(controller.DefaultLabelsAnnotation, "created-by"))
+	})
+
+	It("only reports the default labels in dry-run mode", func() {
+		defaulter.DryRun = true
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}
+		Expect(create(ns)).To(Succeed())
+
+		Expect(ns.Labels).To(BeEmpty())
+		Expect(ns.Annotations).To(BeEmpty())
+	})
+
+	It("leaves namespaces that are not managed alone", func() {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-extra"}}
+		Expect(create(ns)).To(Succeed())
+
+		Expect(ns.Labels).To(BeEmpty())
+		Expect(ns.Annotations).To(BeEmpty())
+	})
+
+	It("turns user names into valid label values", func() {
+		Expect(labelValue("alice@example.com")).To(Equal("alice-example.com"))
+		Expect(labelValue("@ops:")).To(Equal("ops"))
+		Expect(labelValue(strings.Repeat("a", 70))).To(HaveLen(63))
+	})
+})
@@ -0,0 +1,186 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package v1alpha1 holds the admission webhooks for the operator's own
+// v1alpha1 objects.
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+	"github.com/TalDebi/namespacelabel/internal/tracing"
+)
+
+var namespacelabellog = logf.Log.WithName("namespacelabel-resource")
+
+// SetupNamespaceLabelWebhookWithManager registers the webhook for NamespaceLabels in the manager.
+func SetupNamespaceLabelWebhookWithManager(mgr ctrl.Manager, validator *NamespaceLabelCustomValidator) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&danaiov1alpha1.NamespaceLabel{}).
+		WithValidator(validator).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-dana-io-namespacelabel-com-v1alpha1-namespacelabel,mutating=false,failurePolicy=ignore,sideEffects=None,groups=dana.io.namespacelabel.com,resources=namespacelabels,verbs=create;update,versions=v1alpha1,name=vnamespacelabel-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// NamespaceLabelCustomValidator denies NamespaceLabels whose labels would
+// break the policy quota, so an oversized object is never stored. The
+// controller enforces the same quota on what gets through.
+type NamespaceLabelCustomValidator struct {
+	// Client reads the namespace and the other NamespaceLabels in it.
+	Client client.Reader
+
+	mu     sync.RWMutex
+	policy plan.Policy
+}
+
+var _ webhook.CustomValidator = &NamespaceLabelCustomValidator{}
+
+// SetPolicy replaces the policy the quota is read from. It is safe to call
+// while the webhook serves requests.
+func (v *NamespaceLabelCustomValidator) SetPolicy(policy plan.Policy) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.policy = policy
+}
+
+// ValidateCreate implements webhook.CustomValidator.
+func (v *NamespaceLabelCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (_ admission.Warnings, err error) {
+	nl, ok := obj.(*danaiov1alpha1.NamespaceLabel)
+	if !ok {
+		return nil, fmt.Errorf("expected a NamespaceLabel object but got %T", obj)
+	}
+	ctx, span := startSpan(ctx, "ValidateCreate", nl)
+	defer func() { tracing.EndAdmission(span, err) }()
+	return nil, v.validate(ctx, nl)
+}
+
+// ValidateUpdate implements webhook.CustomValidator. Only spec changes that
+// request more labels or more bytes of labels are judged, so the controller
+// can still add its finalizer and a NamespaceLabel over the quota can shrink.
+func (v *NamespaceLabelCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (_ admission.Warnings, err error) {
+	old, ok := oldObj.(*danaiov1alpha1.NamespaceLabel)
+	if !ok {
+		return nil, fmt.Errorf("expected a NamespaceLabel object for the oldObj but got %T", oldObj)
+	}
+	nl, ok := newObj.(*danaiov1alpha1.NamespaceLabel)
+	if !ok {
+		return nil, fmt.Errorf("expected a NamespaceLabel object for the newObj but got %T", newObj)
+	}
+	ctx, span := startSpan(ctx, "ValidateUpdate", nl)
+	defer func() { tracing.EndAdmission(span, err) }()
+	if equality.Semantic.DeepEqual(old.Spec, nl.Spec) {
+		return nil, nil
+	}
+	oldCount, oldBytes := requestedSize(old)
+	count, size := requestedSize(nl)
+	if count <= oldCount && size <= oldBytes {
+		return nil, nil
+	}
+	span.SetAttributes(attribute.Bool("admission.grows", true))
+	return nil, v.validate(ctx, nl)
+}
+
+// startSpan opens the span of the admission handler named verb for nl.
+func startSpan(ctx context.Context, verb string, nl *danaiov1alpha1.NamespaceLabel) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "webhook.NamespaceLabel."+verb,
+		attribute.String("k8s.namespace", nl.Namespace), attribute.String("k8s.name", nl.Name))
+}
+
+// ValidateDelete implements webhook.CustomValidator. The webhook is not
+// registered for deletes.
+func (v *NamespaceLabelCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate plans nl against its namespace as the controller would and
+// denies it if the plan is withheld for breaking the quota. Values read
+// through spec.labelsFrom are not known here, so those keys count with the
+// value nl already owns.
+func (v *NamespaceLabelCustomValidator) validate(ctx context.Context, nl *danaiov1alpha1.NamespaceLabel) error {
+	v.mu.RLock()
+	policy := v.policy
+	v.mu.RUnlock()
+	if policy.Quota == (plan.Quota{}) {
+		return nil
+	}
+
+	// A namespace that is not found yet has no labels.
+	ns := &corev1.Namespace{}
+	if err := v.Client.Get(ctx, client.ObjectKey{Name: nl.Namespace}, ns); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	var list danaiov1alpha1.NamespaceLabelList
+	if err := v.Client.List(ctx, &list, client.InNamespace(nl.Namespace)); err != nil {
+		return err
+	}
+	siblings := []danaiov1alpha1.NamespaceLabel{*nl}
+	for _, sibling := range list.Items {
+		if sibling.Name != nl.Name {
+			siblings = append(siblings, sibling)
+		}
+	}
+
+	var resolved plan.Resolved
+	for _, from := range nl.Spec.LabelsFrom {
+		resolved.Rejected = append(resolved.Rejected, danaiov1alpha1.RejectedLabel{
+			Key:    from.Key,
+			Reason: danaiov1alpha1.RejectedReasonUnresolved,
+		})
+	}
+	p := plan.ForNamespaceLabel(nl, ns, siblings, resolved, policy)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("admission.quota_checked", true))
+	if p.QuotaExceeded == "" {
+		return nil
+	}
+	namespacelabellog.Info("denied a NamespaceLabel over quota", "namespace", nl.Namespace, "name", nl.Name,
+		"reason", p.QuotaExceeded)
+	return apierrors.NewInvalid(danaiov1alpha1.GroupVersion.WithKind("NamespaceLabel").GroupKind(), nl.Name,
+		field.ErrorList{field.Forbidden(field.NewPath("spec"), "the NamespaceLabel "+p.QuotaExceeded)})
+}
+
+// requestedSize returns the number of keys nl requests and their summed
+// length with the values known from its spec.
+func requestedSize(nl *danaiov1alpha1.NamespaceLabel) (count, size int) {
+	requested := maps.Clone(nl.Spec.Labels)
+	if requested == nil {
+		requested = map[string]string{}
+	}
+	for _, from := range nl.Spec.LabelsFrom {
+		if _, ok := requested[from.Key]; !ok {
+			requested[from.Key] = ""
+		}
+	}
+	maps.Copy(requested, plan.PodSecurityLabels(nl.Spec.PodSecurity))
+	return len(requested), plan.LabelBytes(requested)
+}
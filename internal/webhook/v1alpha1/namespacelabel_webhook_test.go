@@ -0,0 +1,145 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	danaiov1alpha1 "github.com/TalDebi/namespacelabel/api/v1alpha1"
+	"github.com/TalDebi/namespacelabel/internal/plan"
+)
+
+var _ = Describe("NamespaceLabel Webhook", func() {
+	var (
+		ctx       context.Context
+		validator *NamespaceLabelCustomValidator
+		owners    *danaiov1alpha1.NamespaceLabel
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(danaiov1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		owners = &danaiov1alpha1.NamespaceLabel{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "owners"},
+			Spec: danaiov1alpha1.NamespaceLabelSpec{
+				Labels: map[string]string{"team": "a"},
+			},
+			Status: danaiov1alpha1.NamespaceLabelStatus{
+				AppliedLabels: map[string]string{"team": "a"},
+			},
+		}
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "team-a",
+			Labels: map[string]string{"team": "a", "manual": "yes"},
+		}}
+		validator = &NamespaceLabelCustomValidator{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(namespace, owners).Build(),
+		}
+		policy := plan.DefaultPolicy()
+		policy.Quota = plan.Quota{MaxLabelsPerNamespace: 4, MaxLabelsPerObject: 3}
+		validator.SetPolicy(policy)
+	})
+
+	newObject := func(labels map[string]string) *danaiov1alpha1.NamespaceLabel {
+		return &danaiov1alpha1.NamespaceLabel{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "labels"},
+			Spec:       danaiov1alpha1.NamespaceLabelSpec{Labels: labels},
+		}
+	}
+
+	It("admits objects within the quota", func() {
+		_, err := validator.ValidateCreate(ctx, newObject(map[string]string{"env": "dev", "tier": "gold"}))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("denies objects requesting too many labels", func() {
+		_, err := validator.ValidateCreate(ctx, newObject(map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}))
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("requests 4 labels, more than the limit of 3"))
+	})
+
+	It("records the decision on a span", func() {
+		recorder := useRecorder()
+		_, err := validator.ValidateCreate(ctx, newObject(map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}))
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+
+		spans := recorder.Ended()
+		Expect(spans).To(HaveLen(1))
+		Expect(spans[0].Name()).To(Equal("webhook.NamespaceLabel.ValidateCreate"))
+		Expect(spans[0].Attributes()).To(ContainElements(
+			attribute.String("k8s.namespace", "team-a"),
+			attribute.String("k8s.name", "labels"),
+			attribute.Bool("admission.quota_checked", true),
+			attribute.Bool("admission.allowed", false)))
+	})
+
+	It("counts the labels already on the namespace", func() {
+		_, err := validator.ValidateCreate(ctx, newObject(map[string]string{"env": "dev", "tier": "gold", "cost": "x"}))
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("would leave 5 labels on the namespace"))
+
+		By("judging an update against the object's own labels")
+		updated := owners.DeepCopy()
+		updated.Spec.Labels = map[string]string{"team": "b", "env": "dev"}
+		_, err = validator.ValidateUpdate(ctx, owners, updated)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("admits updates that leave the spec alone or shrink it", func() {
+		over := newObject(map[string]string{"env": "dev", "tier": "gold", "cost": "x"})
+		_, err := validator.ValidateCreate(ctx, over)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+
+		By("adding a finalizer")
+		finalized := over.DeepCopy()
+		finalized.Finalizers = []string{"dana.io.namespacelabel.com/finalizer"}
+		_, err = validator.ValidateUpdate(ctx, over, finalized)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("dropping a label")
+		shrunk := over.DeepCopy()
+		delete(shrunk.Spec.Labels, "cost")
+		shrunk.Spec.Labels["tier"] = "g"
+		_, err = validator.ValidateUpdate(ctx, over, shrunk)
+		Expect(err).NotTo(HaveOccurred())
+
+		By("growing a label value")
+		grown := over.DeepCopy()
+		grown.Spec.Labels["tier"] = "platinum"
+		_, err = validator.ValidateUpdate(ctx, over, grown)
+		Expect(apierrors.IsInvalid(err)).To(BeTrue())
+	})
+
+	It("admits everything without a quota", func() {
+		validator.SetPolicy(plan.DefaultPolicy())
+		_, err := validator.ValidateCreate(ctx, newObject(map[string]string{"a": "1", "b": "2", "c": "3", "d": "4"}))
+		Expect(err).NotTo(HaveOccurred())
+	})
+})